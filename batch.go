@@ -44,8 +44,13 @@ func QueueNamedExec(batch *pgx.Batch, out *int, namedQuery SQL, argsStruct any)
 // Version of Query which queues to a batch.
 // Writes results into *out (which must not be nil) when the batch is run.
 func QueueQuery[T any](batch *pgx.Batch, out *[]T, query SQL, args ...any) {
+	QueueQueryWithOptions(batch, out, DefaultScanOptions, query, args...)
+}
+
+// Version of QueueQuery with explicit options for matching columns to struct fields.
+func QueueQueryWithOptions[T any](batch *pgx.Batch, out *[]T, opts ScanOptions, query SQL, args ...any) {
 	batch.Queue(string(query), args...).Query(func(cursor pgx.Rows) error {
-		return ScanRows(cursor, out)
+		return ScanRowsWithOptions(cursor, out, opts)
 	})
 }
 
@@ -60,10 +65,13 @@ func QueueQueryOne[T any](batch *pgx.Batch, out *T, query SQL, args ...any) {
 // Version of Query which queues to a batch.
 // Writes results into *out (which must not be nil) when the batch is run.
 func QueueNamedQuery[T any](batch *pgx.Batch, out *[]T, namedQuery SQL, argsStruct any) {
+	QueueNamedQueryWithOptions(batch, out, DefaultScanOptions, namedQuery, argsStruct)
+}
+
+// Version of QueueNamedQuery with explicit options for matching columns to struct fields.
+func QueueNamedQueryWithOptions[T any](batch *pgx.Batch, out *[]T, opts ScanOptions, namedQuery SQL, argsStruct any) {
 	query, args := ExtractNamedQuery(namedQuery, argsStruct)
-	batch.Queue(string(query), args...).Query(func(cursor pgx.Rows) error {
-		return ScanRows(cursor, out)
-	})
+	QueueQueryWithOptions(batch, out, opts, query, args...)
 }
 
 // Version of NamedQueryOne which queues to a batch.
@@ -74,3 +82,17 @@ func QueueNamedQueryOne[T any](batch *pgx.Batch, out *T, namedQuery SQL, argsStr
 		return ScanSingleRow(cursor, out, false)
 	})
 }
+
+// Version of QueueNamedExec for queries which also use positional parameters (such as $1) with values given by extra,
+// numbering named parameters after the highest positional one.
+func QueueNamedExecWithArgs(batch *pgx.Batch, out *int, namedQuery SQL, argsStruct any, extra ...any) {
+	query, args := ExtractNamedQueryWithArgs(namedQuery, argsStruct, extra...)
+	QueueExec(batch, out, query, args...)
+}
+
+// Version of QueueNamedQuery for queries which also use positional parameters (such as $1) with values given by extra,
+// numbering named parameters after the highest positional one.
+func QueueNamedQueryWithArgs[T any](batch *pgx.Batch, out *[]T, namedQuery SQL, argsStruct any, extra ...any) {
+	query, args := ExtractNamedQueryWithArgs(namedQuery, argsStruct, extra...)
+	QueueQuery(batch, out, query, args...)
+}
@@ -0,0 +1,184 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"reflect"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+
+	"github.com/george-steel/pgxx"
+)
+
+const pgxxPath = "github.com/george-steel/pgxx"
+
+// Analyzer checking named queries passed to pgxx functions against the db fields of their argument structs.
+var Analyzer = &analysis.Analyzer{
+	Name:     "pgxxvet",
+	Doc:      "check that named parameters of constant pgxx queries match the db tags of their argument structs",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+var namingFlag string
+
+func init() {
+	Analyzer.Flags.StringVar(&namingFlag, "naming", "none",
+		"default naming policy set at runtime with pgxx.SetDefaultNamingPolicy: none, snake, lower, or custom "+
+			"(with custom, structs with untagged fields and no naming marker of their own are not checked)")
+}
+
+// Returns the policy given by the naming flag, or false if it is custom and so cannot be known.
+func defaultNamingPolicy() (pgxx.NamingPolicy, bool, error) {
+	switch namingFlag {
+	case "none":
+		return nil, true, nil
+	case "snake":
+		return pgxx.SnakeCase, true, nil
+	case "lower":
+		return pgxx.LowerCase, true, nil
+	case "custom":
+		return nil, false, nil
+	default:
+		return nil, false, fmt.Errorf("invalid -naming value %q: must be none, snake, lower, or custom", namingFlag)
+	}
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	policy, policyKnown, err := defaultNamingPolicy()
+	if err != nil {
+		return nil, err
+	}
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	insp.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+		if !ok || fn.Pkg() == nil || fn.Pkg().Path() != pgxxPath {
+			return
+		}
+		queryIdx, argsIdx := namedQueryParams(fn.Type().(*types.Signature))
+		if argsIdx < 0 || argsIdx >= len(call.Args) {
+			return
+		}
+		queryArg := call.Args[queryIdx]
+		tv := pass.TypesInfo.Types[queryArg]
+		if tv.Value == nil || tv.Value.Kind() != constant.String {
+			// only constant queries can be checked
+			return
+		}
+
+		_, params, err := pgxx.TryRewriteNamedQuery(pgxx.SQL(constant.StringVal(tv.Value)))
+		if err != nil {
+			pass.Reportf(queryArg.Pos(), "malformed named query: %v", err)
+			return
+		}
+		argsType := pass.TypesInfo.TypeOf(call.Args[argsIdx])
+		fields := make(map[pgxx.FieldName]bool)
+		if !collectFields(fields, argsType, "", "", policy, policyKnown, 0) {
+			// maps, merged sources, and types with a custom naming policy are not checked
+			return
+		}
+		for _, p := range params {
+			if !fields[p] {
+				pass.Reportf(queryArg.Pos(), "named parameter @%s is not a db field of %s", p, types.TypeString(argsType, types.RelativeTo(pass.Pkg)))
+			}
+		}
+	})
+	return nil, nil
+}
+
+// Finds the positions of the named query and argument struct parameters of a pgxx function,
+// which are an SQL parameter followed by one called argsStruct.
+func namedQueryParams(sig *types.Signature) (int, int) {
+	params := sig.Params()
+	for i := 1; i < params.Len(); i++ {
+		if params.At(i).Name() != "argsStruct" {
+			continue
+		}
+		if named, ok := params.At(i - 1).Type().(*types.Named); ok && named.Obj().Name() == "SQL" {
+			return i - 1, i
+		}
+	}
+	return -1, -1
+}
+
+// Adds the db fields of a struct type (and their dotted paths) to fields, following the same rules as the struct mapper.
+// Untagged fields are named by policy, which comes from the naming flag until a struct declares its own,
+// and is unknown if policyKnown is false.
+// Returns false if the fields cannot be determined.
+func collectFields(fields map[pgxx.FieldName]bool, t types.Type, prefix, dotted string, policy pgxx.NamingPolicy, policyKnown bool, depth int) bool {
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok || depth > 10 {
+		return false
+	}
+	if hasMethod(t, "DBColumnName") {
+		return false
+	}
+	for i := range st.NumFields() {
+		if f := st.Field(i); f.Anonymous() && isPgxxType(f.Type(), "SnakeCaseColumns") {
+			policy, policyKnown = pgxx.SnakeCase, true
+		} else if f.Anonymous() && isPgxxType(f.Type(), "LowerCaseColumns") {
+			policy, policyKnown = pgxx.LowerCase, true
+		}
+	}
+
+	for i := range st.NumFields() {
+		f := st.Field(i)
+		tag := reflect.StructTag(st.Tag(i))
+		rawtag := tag.Get("db")
+		dbtag, _, _ := strings.Cut(rawtag, ",")
+		prefixtag, isprefix := tag.Lookup("db_prefix")
+		_, ischildren := tag.Lookup("db_children")
+		if !f.Exported() || rawtag == "-" {
+			continue
+		}
+
+		name := dbtag
+		if name == "" && !f.Anonymous() && !isprefix && !ischildren {
+			if !policyKnown {
+				return false
+			} else if policy != nil {
+				name = policy(f.Name())
+			}
+		}
+
+		if name != "" {
+			fields[pgxx.FieldName(prefix+name)] = true
+			if dotted != "" {
+				fields[pgxx.FieldName(dotted+name)] = true
+			}
+		} else if f.Anonymous() || isprefix {
+			segment := strings.TrimSuffix(prefixtag, "_")
+			if segment != "" {
+				segment += "."
+			}
+			if !collectFields(fields, f.Type(), prefix+prefixtag, dotted+segment, policy, policyKnown, depth+1) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isPgxxType(t types.Type, name string) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == pgxxPath && named.Obj().Name() == name
+}
+
+func hasMethod(t types.Type, name string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(t), true, nil, name)
+	_, isFunc := obj.(*types.Func)
+	return isFunc
+}
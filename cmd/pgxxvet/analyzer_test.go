@@ -0,0 +1,22 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "a")
+}
+
+func TestAnalyzerNamingFlag(t *testing.T) {
+	defer Analyzer.Flags.Set("naming", "none")
+	Analyzer.Flags.Set("naming", "snake")
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "b")
+	Analyzer.Flags.Set("naming", "custom")
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "c")
+}
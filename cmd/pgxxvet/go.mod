@@ -0,0 +1,20 @@
+module github.com/george-steel/pgxx/cmd/pgxxvet
+
+go 1.23.0
+
+toolchain go1.23.6
+
+require (
+	github.com/george-steel/pgxx v0.1.0
+	golang.org/x/tools v0.30.0
+)
+
+require (
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
+	github.com/jackc/pgx/v5 v5.7.2 // indirect
+	golang.org/x/crypto v0.35.0 // indirect
+	golang.org/x/mod v0.23.0 // indirect
+	golang.org/x/sync v0.11.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
+)
@@ -0,0 +1,17 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+// Pgxxvet checks that the named parameters of constant queries passed to pgxx
+// (such as with NamedExec, NamedQuery, and QueueNamedExec) match the db tags of the struct they are extracted from,
+// catching at compile time mismatches which would otherwise panic at runtime.
+//
+// It can be run directly (as `pgxxvet ./...`) or as part of go vet (as `go vet -vettool=$(which pgxxvet) ./...`).
+// If the program sets a default naming policy with pgxx.SetDefaultNamingPolicy, pass the same one with
+// the -naming flag (as snake, lower, or custom) so that untagged fields are named as they are at runtime.
+package main
+
+import "golang.org/x/tools/go/analysis/singlechecker"
+
+func main() {
+	singlechecker.Main(Analyzer)
+}
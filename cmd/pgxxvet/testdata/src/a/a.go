@@ -0,0 +1,51 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package a
+
+import (
+	"context"
+
+	"github.com/george-steel/pgxx"
+)
+
+type User struct {
+	UserID int    `db:"user_id,pk,generated"`
+	Name   string `db:"name"`
+	Notes  string `db:"-"`
+}
+
+type Account struct {
+	ID    int   `db:"account_id"`
+	Owner *User `db_prefix:"owner_"`
+}
+
+type Untagged struct {
+	pgxx.SnakeCaseColumns
+	FirstName string
+}
+
+// untagged fields are ignored without a naming policy
+type Plain struct {
+	UserID int
+	Name   string `db:"name"`
+}
+
+const updateUser = "UPDATE users SET name = @name WHERE user_id = @user_id"
+
+func queries(ctx context.Context, conn pgxx.PoolOrTx, u User, acct *Account, dynamic pgxx.SQL) {
+	pgxx.NamedExec(ctx, conn, updateUser, u)
+	pgxx.NamedExec(ctx, conn, "UPDATE users SET notes = @notes WHERE user_id = @user_id", &u) // want `named parameter @notes is not a db field of \*User`
+	pgxx.NamedQuery[int](ctx, conn, "SELECT @owner_name, @owner.user_id, @account_id", acct)
+	pgxx.NamedQuery[int](ctx, conn, "SELECT @owner.nmae", acct) // want `named parameter @owner.nmae is not a db field of \*Account`
+	pgxx.NamedQueryWithArgs[int](ctx, conn, "SELECT @first_name LIMIT $1", Untagged{}, 10)
+	pgxx.QueueNamedExec(nil, nil, "DELETE FROM users WHERE name = @nam", u) // want `named parameter @nam is not a db field of User`
+	pgxx.NamedExec(ctx, conn, "SELECT @name, @user_id", Plain{})            // want `named parameter @user_id is not a db field of Plain`
+	pgxx.NamedExec(ctx, conn, "SELECT 'unterminated @name", u)              // want `malformed named query: unterminated string literal`
+
+	// not checked: dynamic queries, maps, merged sources, and positional queries
+	pgxx.NamedExec(ctx, conn, dynamic, u)
+	pgxx.NamedExec(ctx, conn, "SELECT @anything", map[string]any{})
+	pgxx.NamedExec(ctx, conn, "SELECT @anything", pgxx.MergeArgs(u, map[string]any{}))
+	pgxx.Exec(ctx, conn, "SELECT @anything")
+}
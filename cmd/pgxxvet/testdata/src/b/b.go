@@ -0,0 +1,29 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+// Checked with -naming=snake.
+package b
+
+import (
+	"context"
+
+	"github.com/george-steel/pgxx"
+)
+
+type Plain struct {
+	UserID int
+	Name   string `db:"name"`
+}
+
+type Lower struct {
+	pgxx.LowerCaseColumns
+	UserID int
+}
+
+func queries(ctx context.Context, conn pgxx.PoolOrTx) {
+	pgxx.NamedExec(ctx, conn, "SELECT @name, @user_id", Plain{})
+	pgxx.NamedExec(ctx, conn, "SELECT @userid", Plain{}) // want `named parameter @userid is not a db field of Plain`
+	// markers take precedence over the default policy
+	pgxx.NamedExec(ctx, conn, "SELECT @userid", Lower{})
+	pgxx.NamedExec(ctx, conn, "SELECT @user_id", Lower{}) // want `named parameter @user_id is not a db field of Lower`
+}
@@ -0,0 +1,26 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+// Checked with -naming=custom.
+package c
+
+import (
+	"context"
+
+	"github.com/george-steel/pgxx"
+)
+
+type Plain struct {
+	UserID int
+	Name   string `db:"name"`
+}
+
+type Tagged struct {
+	Name string `db:"name"`
+}
+
+func queries(ctx context.Context, conn pgxx.PoolOrTx) {
+	// the names of untagged fields are unknown
+	pgxx.NamedExec(ctx, conn, "SELECT @anything", Plain{})
+	pgxx.NamedExec(ctx, conn, "SELECT @nme", Tagged{}) // want `named parameter @nme is not a db field of Tagged`
+}
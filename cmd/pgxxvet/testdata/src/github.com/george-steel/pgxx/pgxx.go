@@ -0,0 +1,37 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+// Stub of the pgxx API for analyzer tests.
+package pgxx
+
+import "context"
+
+type SQL string
+
+type PoolOrTx interface{}
+
+type MergedArgs []any
+
+type SnakeCaseColumns struct{}
+
+type LowerCaseColumns struct{}
+
+func MergeArgs(sources ...any) MergedArgs { return sources }
+
+func NamedExec(ctx context.Context, conn PoolOrTx, namedQuery SQL, argsStruct any) (int, error) {
+	return 0, nil
+}
+
+func NamedQuery[T any](ctx context.Context, conn PoolOrTx, namedQuery SQL, argsStruct any) ([]T, error) {
+	return nil, nil
+}
+
+func NamedQueryWithArgs[T any](ctx context.Context, conn PoolOrTx, namedQuery SQL, argsStruct any, extra ...any) ([]T, error) {
+	return nil, nil
+}
+
+func QueueNamedExec(batch any, out *int, namedQuery SQL, argsStruct any) {}
+
+func Exec(ctx context.Context, conn PoolOrTx, query SQL, args ...any) (int, error) {
+	return 0, nil
+}
@@ -0,0 +1,238 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Codec for a Postgres composite type which reads and writes its fields into and out of structs
+// using the mapping defined by db and db_prefix tags, and falls back to the underlying CompositeCodec for other types.
+type structCompositeCodec struct {
+	*pgtype.CompositeCodec
+	structs map[reflect.Type]*compositeFields
+}
+
+// Struct fields holding the fields of a composite type, taken from the mapping of a struct type.
+type compositeFields struct {
+	paths [][]int // field path of each composite field, nil if unmapped
+	json  []bool  // composite fields mapped to struct fields tagged with the json option
+}
+
+// Wraps a struct value as both a CompositeIndexScanner and CompositeIndexGetter.
+type compositeStruct struct {
+	val    reflect.Value // addressable struct, or pointer to struct when encoding
+	fields *compositeFields
+}
+
+func (c compositeStruct) ScanNull() error {
+	c.val.SetZero()
+	return nil
+}
+
+func (c compositeStruct) ScanIndex(i int) any {
+	path := c.fields.paths[i]
+	if path == nil {
+		// unmapped fields are skipped
+		return nil
+	}
+	fval := fieldByPath(c.val, path)
+	if c.fields.json[i] {
+		return &jsonField{fval}
+	}
+	return fval.Addr().Interface()
+}
+
+func (c compositeStruct) IsNull() bool {
+	return c.val.Kind() == reflect.Pointer && c.val.IsNil()
+}
+
+func (c compositeStruct) Index(i int) any {
+	path := c.fields.paths[i]
+	if path == nil {
+		return nil
+	}
+	fval, err := reflect.Indirect(c.val).FieldByIndexErr(path)
+	if err != nil {
+		// fields inside a nil embedded pointer are NULL
+		return nil
+	}
+	if c.fields.json[i] {
+		return jsonField{fval}
+	}
+	return fval.Interface()
+}
+
+// Composite field tagged with the json option, decoded when scanned and encoded when used as a value.
+type jsonField struct {
+	fval reflect.Value
+}
+
+func (j *jsonField) Scan(src any) error {
+	var raw jsonScanTarget
+	err := raw.Scan(src)
+	if err != nil {
+		return err
+	}
+	return raw.decodeInto(j.fval)
+}
+
+func (j jsonField) Value() (driver.Value, error) {
+	return jsonArg(j.fval)
+}
+
+type scanPlanCompositeToStruct struct {
+	next   pgtype.ScanPlan
+	fields *compositeFields
+}
+
+func (plan *scanPlanCompositeToStruct) Scan(src []byte, target any) error {
+	return plan.next.Scan(src, compositeStruct{val: reflect.ValueOf(target).Elem(), fields: plan.fields})
+}
+
+type encodePlanStructToComposite struct {
+	next   pgtype.EncodePlan
+	fields *compositeFields
+}
+
+func (plan *encodePlanStructToComposite) Encode(value any, buf []byte) ([]byte, error) {
+	return plan.next.Encode(compositeStruct{val: reflect.ValueOf(value), fields: plan.fields}, buf)
+}
+
+func (c *structCompositeCodec) PlanScan(m *pgtype.Map, oid uint32, format int16, target any) pgtype.ScanPlan {
+	if t := reflect.TypeOf(target); t.Kind() == reflect.Pointer {
+		if fields, ok := c.structs[t.Elem()]; ok {
+			next := c.CompositeCodec.PlanScan(m, oid, format, compositeStruct{})
+			if next == nil {
+				return nil
+			}
+			return &scanPlanCompositeToStruct{next: next, fields: fields}
+		}
+	}
+	return c.CompositeCodec.PlanScan(m, oid, format, target)
+}
+
+func (c *structCompositeCodec) PlanEncode(m *pgtype.Map, oid uint32, format int16, value any) pgtype.EncodePlan {
+	t := reflect.TypeOf(value)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if fields, ok := c.structs[t]; ok {
+		next := c.CompositeCodec.PlanEncode(m, oid, format, compositeStruct{})
+		if next == nil {
+			return nil
+		}
+		return &encodePlanStructToComposite{next: next, fields: fields}
+	}
+	return c.CompositeCodec.PlanEncode(m, oid, format, value)
+}
+
+// Wraps the codec of a composite type loaded from the database to read and write the given struct types.
+func makeStructCompositeCodec(dt *pgtype.Type, structTypes []reflect.Type) (*pgtype.Type, error) {
+	cc, ok := dt.Codec.(*pgtype.CompositeCodec)
+	if !ok {
+		if sc, isWrapped := dt.Codec.(*structCompositeCodec); isWrapped {
+			cc = sc.CompositeCodec
+		} else {
+			return nil, fmt.Errorf("type %s is not a composite type", dt.Name)
+		}
+	}
+	codec := &structCompositeCodec{
+		CompositeCodec: cc,
+		structs:        make(map[reflect.Type]*compositeFields),
+	}
+	for _, st := range structTypes {
+		if !isMappable(st) || st.Kind() == reflect.Pointer {
+			return nil, fmt.Errorf("cannot map composite type %s to non-struct %v", dt.Name, st)
+		}
+		mapping := structMappingOf(st)
+		fields := &compositeFields{
+			paths: make([][]int, len(cc.Fields)),
+			json:  make([]bool, len(cc.Fields)),
+		}
+		for i, f := range cc.Fields {
+			fields.paths[i] = mapping.FieldMappings[FieldName(f.Name)]
+			fields.json[i] = mapping.FieldOptions[FieldName(f.Name)].has("json")
+		}
+		codec.structs[st] = fields
+	}
+	return &pgtype.Type{Name: dt.Name, OID: dt.OID, Codec: codec}, nil
+}
+
+var compositeTypeNames []string
+var compositeTypes = make(map[string][]reflect.Type)
+var compositeTypesLock sync.Mutex
+
+// Declares that a struct type (with fields mapped using db and db_prefix tags) is used to hold values of
+// a Postgres composite type, and that slices of it are used to hold arrays of that type.
+// Multiple struct types may be declared for the same composite type.
+// Declared types are registered on connections by [RegisterCompositeTypes].
+// Composite types containing other composite types must be declared after the types they contain.
+func DeclareCompositeType[T any](typeName string) {
+	compositeTypesLock.Lock()
+	defer compositeTypesLock.Unlock()
+	if _, found := compositeTypes[typeName]; !found {
+		compositeTypeNames = append(compositeTypeNames, typeName)
+	}
+	compositeTypes[typeName] = append(compositeTypes[typeName], reflect.TypeFor[T]())
+}
+
+// Registers all composite types declared using [DeclareCompositeType] (as well as their array types) on a connection.
+// Has the correct signature to be used as the AfterConnect hook of a pgxpool.Config.
+func RegisterCompositeTypes(ctx context.Context, conn *pgx.Conn) error {
+	compositeTypesLock.Lock()
+	names := append([]string(nil), compositeTypeNames...)
+	types := make(map[string][]reflect.Type, len(names))
+	for _, name := range names {
+		types[name] = append([]reflect.Type(nil), compositeTypes[name]...)
+	}
+	compositeTypesLock.Unlock()
+
+	for _, name := range names {
+		err := RegisterCompositeType(ctx, conn, name, types[name]...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Registers a single Postgres composite type (and its array type) on a connection,
+// to be read and written using the given struct types (and slices of them).
+func RegisterCompositeType(ctx context.Context, conn *pgx.Conn, typeName string, structTypes ...reflect.Type) error {
+	dt, err := conn.LoadType(ctx, typeName)
+	if err != nil {
+		return fmt.Errorf("error loading composite type %s: %w", typeName, err)
+	}
+	dt, err = makeStructCompositeCodec(dt, structTypes)
+	if err != nil {
+		return err
+	}
+	typeMap := conn.TypeMap()
+	typeMap.RegisterType(dt)
+
+	arrayName := "_" + typeName
+	if dot := strings.LastIndexByte(typeName, '.'); dot >= 0 {
+		arrayName = typeName[:dot+1] + "_" + typeName[dot+1:]
+	}
+	arrayType, err := conn.LoadType(ctx, arrayName)
+	if err != nil {
+		return fmt.Errorf("error loading array type %s: %w", arrayName, err)
+	}
+	typeMap.RegisterType(arrayType)
+
+	for _, st := range structTypes {
+		typeMap.RegisterDefaultPgType(reflect.New(st).Elem().Interface(), typeName)
+		typeMap.RegisterDefaultPgType(reflect.New(reflect.SliceOf(st)).Elem().Interface(), arrayName)
+	}
+	return nil
+}
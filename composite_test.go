@@ -0,0 +1,91 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type compositeAddress struct {
+	Number int     `db:"number"`
+	Street string  `db:"street"`
+	Unit   *string `db:"unit"`
+}
+
+func TestStructCompositeCodec(t *testing.T) {
+	m := pgtype.NewMap()
+	textType, _ := m.TypeForName("text")
+	intType, _ := m.TypeForName("int4")
+	loaded := &pgtype.Type{Name: "address", OID: 100001, Codec: &pgtype.CompositeCodec{Fields: []pgtype.CompositeCodecField{
+		{Name: "street", Type: textType},
+		{Name: "number", Type: intType},
+		{Name: "unit", Type: textType},
+		{Name: "postcode", Type: textType},
+	}}}
+	dt, err := makeStructCompositeCodec(loaded, []reflect.Type{reflect.TypeFor[compositeAddress]()})
+	require.NoError(t, err)
+	m.RegisterType(dt)
+	m.RegisterType(&pgtype.Type{Name: "_address", OID: 100002, Codec: &pgtype.ArrayCodec{ElementType: dt}})
+
+	// fields are matched by name, and unmapped fields are discarded
+	var addr compositeAddress
+	err = m.Scan(dt.OID, pgtype.TextFormatCode, []byte("(Main St,12,,A1B2C3)"), &addr)
+	assert.NoError(t, err)
+	assert.Equal(t, compositeAddress{Number: 12, Street: "Main St"}, addr)
+
+	var addrs []compositeAddress
+	err = m.Scan(100002, pgtype.TextFormatCode, []byte(`{"(First,1,2,)","(Second,3,,)"}`), &addrs)
+	assert.NoError(t, err)
+	two := "2"
+	assert.Equal(t, []compositeAddress{{Number: 1, Street: "First", Unit: &two}, {Number: 3, Street: "Second"}}, addrs)
+
+	var nullAddr *compositeAddress
+	err = m.Scan(dt.OID, pgtype.TextFormatCode, nil, &nullAddr)
+	assert.NoError(t, err)
+	assert.Nil(t, nullAddr)
+
+	buf, err := m.Encode(dt.OID, pgtype.TextFormatCode, compositeAddress{Number: 5, Street: "Elm", Unit: &two}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "(Elm,5,2,)", string(buf))
+}
+
+type compositeTagged struct {
+	Name string         `db:"name"`
+	Meta map[string]int `db:"meta,json"`
+}
+
+func TestStructCompositeCodecJSON(t *testing.T) {
+	m := pgtype.NewMap()
+	textType, _ := m.TypeForName("text")
+	jsonbType, _ := m.TypeForName("jsonb")
+	loaded := &pgtype.Type{Name: "tagged", OID: 100003, Codec: &pgtype.CompositeCodec{Fields: []pgtype.CompositeCodecField{
+		{Name: "name", Type: textType},
+		{Name: "meta", Type: jsonbType},
+	}}}
+	dt, err := makeStructCompositeCodec(loaded, []reflect.Type{reflect.TypeFor[compositeTagged]()})
+	require.NoError(t, err)
+	m.RegisterType(dt)
+
+	// fields tagged with the json option are decoded and encoded as in rows and parameters
+	var tagged compositeTagged
+	err = m.Scan(dt.OID, pgtype.TextFormatCode, []byte(`(x,"{""k"": 1}")`), &tagged)
+	assert.NoError(t, err)
+	assert.Equal(t, compositeTagged{Name: "x", Meta: map[string]int{"k": 1}}, tagged)
+
+	err = m.Scan(dt.OID, pgtype.TextFormatCode, []byte(`(y,)`), &tagged)
+	assert.NoError(t, err)
+	assert.Equal(t, compositeTagged{Name: "y"}, tagged)
+
+	buf, err := m.Encode(dt.OID, pgtype.TextFormatCode, compositeTagged{Name: "z", Meta: map[string]int{"k": 2}}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `(z,"{\"k\":2}")`, string(buf))
+	buf, err = m.Encode(dt.OID, pgtype.TextFormatCode, compositeTagged{Name: "z"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `(z,)`, string(buf))
+}
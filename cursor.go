@@ -5,7 +5,9 @@ package pgxx
 
 import (
 	"fmt"
+	"iter"
 	"reflect"
+	"slices"
 
 	"github.com/jackc/pgx/v5"
 )
@@ -13,31 +15,59 @@ import (
 // Bidirectional mapping between structs, cursors, and queries.
 //
 // Converts a query with named parameters (using the @param syntak of pgx.NamedArgs)
-// to one using positional parameters.
-// Panics if a query does not match the type of struct given, to simplify use with hardcoded queries.
+// to one using positional parameters, taking their values from a struct (or pointer to struct),
+// a map[string]any or pgx.NamedArgs, or several of these combined using [MergeArgs].
+// A parameter followed by `...` (as in `WHERE id IN (@ids...)`) must be a non-empty slice,
+// and is expanded into one positional parameter per element.
+// Expansion is not needed when comparing against an array parameter, as in `WHERE id = ANY(@ids)`,
+// which also allows the slice to be empty.
+// Panics if a query does not match the type of struct given (or an expanded slice is empty),
+// to simplify use with hardcoded queries.
 func ExtractNamedQuery(query SQL, argsStruct any) (SQL, []any) {
-	posQuery, fields := RewriteNamedQuery(query)
-	val := reflect.Indirect(reflect.ValueOf(argsStruct))
-	mapping := structMappingOf(val.Type())
-
-	args, err := mapping.extractNamedArgs(fields, val)
+	posQuery, args, err := extractNamedQuery(parsedQueryOf(query), argsStruct)
 	if err != nil {
 		panic(err)
 	}
 	return posQuery, args
 }
 
-// Error-tolerant version of ExtractNamedQuery for use with dynamic query strings
+// Error-tolerant version of ExtractNamedQuery for use with dynamic query strings.
+// Also returns a [*QuerySyntaxError] if the query ends inside a quote or block comment,
+// which ExtractNamedQuery leaves for the server to report.
 func MaybeExtractNamedQuery(query SQL, argsStruct any) (SQL, []any, error) {
-	posQuery, fields := RewriteNamedQuery(query)
-	val := reflect.Indirect(reflect.ValueOf(argsStruct))
-	mapping := structMappingOf(val.Type())
+	parsed := parsedQueryOf(query)
+	if parsed.err != nil {
+		return "", nil, parsed.err
+	}
+	return extractNamedQuery(parsed, argsStruct)
+}
 
-	args, err := mapping.extractNamedArgs(fields, val)
+func extractNamedQuery(parsed *parsedQuery, argsStruct any) (SQL, []any, error) {
+	args, err := extractNamedArgs(parsed.fields, argsStruct)
 	if err != nil {
 		return "", nil, err
 	}
-	return posQuery, args, nil
+	return parsed.render(args, 0)
+}
+
+// Version of ExtractNamedQuery for queries which also use positional parameters (such as $1),
+// whose values are given by extra. Named parameters are numbered after the highest positional parameter,
+// and the values of both are returned together.
+// Panics if the number of extra values does not match the highest positional parameter used.
+func ExtractNamedQueryWithArgs(query SQL, argsStruct any, extra ...any) (SQL, []any) {
+	parsed := parsedQueryOf(query)
+	if len(extra) != parsed.maxOrdinal {
+		panic(fmt.Errorf("query uses %d positional parameters, but %d were given", parsed.maxOrdinal, len(extra)))
+	}
+	args, err := extractNamedArgs(parsed.fields, argsStruct)
+	if err != nil {
+		panic(err)
+	}
+	posQuery, namedArgs, err := parsed.render(args, len(extra))
+	if err != nil {
+		panic(err)
+	}
+	return posQuery, append(slices.Clip(extra), namedArgs...)
 }
 
 // Extracts fields from a slice of structs for a CopyFrom (bulk insert) query
@@ -55,118 +85,173 @@ func ExtractCopyParams[T any](fields []FieldName, records []T) [][]any {
 	return out
 }
 
-// Scan rows to a slice of either structs (using the mapping defined by db and db_prefix tags)
-// or single values (for queries returning a single column).
-func ScanRows[T any](rows pgx.Rows, dst *[]T) error {
-	defer rows.Close()
-	t := reflect.TypeFor[T]()
-	if isMappable(t) {
-		// scanning into a struct that is meant to hold multiple columns
-		mapping := structMappingOf(t)
-		fields := rows.FieldDescriptions()
-		cols := make([]FieldName, len(fields))
-		for i, fd := range fields {
-			cols[i] = FieldName(fd.Name)
-		}
+// Options controlling how the columns of a result set are matched to struct fields.
+type ScanOptions struct {
+	// If set, errors on every result column without a matching struct field
+	// and every db-tagged struct field not set by any column, reporting them all at once.
+	// Mismatches are reported before the first row is scanned, even if the result set is empty.
+	Strict bool
+	// If set, strict scans report unfilled fields to this function instead of returning an error.
+	// Unmapped columns are still an error.
+	WarnUnfilled func(mismatch *ScanMismatchError)
+	// If set, result columns without a matching struct field are discarded instead of causing an error
+	// (even in strict mode), so that queries such as SELECT * keep working when columns are added.
+	IgnoreExtraColumns bool
+}
 
-		*dst = (*dst)[:0]
-		for rows.Next() {
-			var record T
-			ptrs, err := mapping.extractScanPointers(cols, reflect.ValueOf(&record))
+// Options used when scanning rows into structs, unless overridden per call using [ScanRowsWithOptions],
+// [QueryWithOptions], or the other WithOptions functions. Changeable.
+var DefaultScanOptions ScanOptions
+
+// Prepares a function scanning the current row of a cursor into either a struct
+// (using the mapping defined by db and db_prefix tags), a single value (for queries returning a single column),
+// or a dynamic Record or map[string]any (for queries returning any columns).
+func rowScannerFor[T any](rows pgx.Rows, opts ScanOptions) (func(dst *T) error, error) {
+	t := reflect.TypeFor[T]()
+	switch any((*T)(nil)).(type) {
+	case *Record, **Record, *map[string]any:
+		// scanning a dynamic record of any number of columns
+		return func(dst *T) error {
+			rec, err := scanRecord(rows)
 			if err != nil {
 				return err
 			}
-			err = rows.Scan(ptrs...)
-			if err != nil {
-				return err
+			switch dst := any(dst).(type) {
+			case *Record:
+				*dst = rec
+			case **Record:
+				*dst = &rec
+			case *map[string]any:
+				*dst = rec.Map()
 			}
-			*dst = append(*dst, record)
+			return nil
+		}, nil
+	}
+	if isMappable(t) {
+		// scanning into a struct that is meant to hold multiple columns
+		// using a plan computed once per type and column list, with pointers reused between rows
+		plan := scanPlanOf(t, rows.FieldDescriptions())
+		err := plan.check(opts)
+		if err != nil {
+			return nil, err
 		}
-		return rows.Err()
+		scan := plan.rowScanner(rows, opts.IgnoreExtraColumns)
+		return func(dst *T) error {
+			return scan(reflect.ValueOf(dst))
+		}, nil
 	} else {
 		// scanning a single column into a primitive type or a Scanner struct
 		if len(rows.FieldDescriptions()) != 1 {
 			panic(fmt.Errorf("expected a single column with return type %v, got %v", t, rows.FieldDescriptions()))
 		}
-		*dst = (*dst)[:0]
-		for rows.Next() {
-			var record T
-			err := rows.Scan(&record)
-			if err != nil {
-				return err
-			}
-			*dst = append(*dst, record)
+		return func(dst *T) error {
+			return rows.Scan(dst)
+		}, nil
+	}
+}
+
+// Scan rows to a slice of either structs (using the mapping defined by db and db_prefix tags)
+// or single values (for queries returning a single column).
+func ScanRows[T any](rows pgx.Rows, dst *[]T) error {
+	return ScanRowsWithOptions(rows, dst, DefaultScanOptions)
+}
+
+// Version of ScanRows with explicit options for matching columns to struct fields.
+func ScanRowsWithOptions[T any](rows pgx.Rows, dst *[]T, opts ScanOptions) error {
+	defer rows.Close()
+	scan, err := rowScannerFor[T](rows, opts)
+	if err != nil {
+		return err
+	}
+
+	*dst = (*dst)[:0]
+	for rows.Next() {
+		// scan in place to avoid copying (and allocating) each record
+		var zero T
+		n := len(*dst)
+		*dst = append(*dst, zero)
+		err = scan(&(*dst)[n])
+		if err != nil {
+			*dst = (*dst)[:n]
+			return err
 		}
-		return rows.Err()
 	}
+	return rows.Err()
 }
 
 // Scan a single row from a query result.
 // If requireExact is true, errors on an empty result set or too many rows,
 // if it is false, discard extra rows and leave dst unchanged if empty.
 func ScanSingleRow[T any](rows pgx.Rows, dst *T, requireExact bool) error {
-	defer rows.Close()
-	t := reflect.TypeFor[T]()
-	if isMappable(t) {
-		// scanning into a struct that is meant to hold multiple columns
-		mapping := structMappingOf(t)
-		fields := rows.FieldDescriptions()
-		cols := make([]FieldName, len(fields))
-		for i, fd := range fields {
-			cols[i] = FieldName(fd.Name)
-		}
+	return ScanSingleRowWithOptions(rows, dst, requireExact, DefaultScanOptions)
+}
 
-		if rows.Next() {
-			ptrs, err := mapping.extractScanPointers(cols, reflect.ValueOf(dst))
-			if err != nil {
-				return err
-			}
+// Version of ScanSingleRow with explicit options for matching columns to struct fields.
+func ScanSingleRowWithOptions[T any](rows pgx.Rows, dst *T, requireExact bool, opts ScanOptions) error {
+	defer rows.Close()
+	scan, err := rowScannerFor[T](rows, opts)
+	if err != nil {
+		return err
+	}
 
-			err = rows.Scan(ptrs...)
-			if err != nil {
-				return err
-			}
-			if requireExact {
-				if rows.Next() {
-					return pgx.ErrTooManyRows
-				}
+	if rows.Next() {
+		err = scan(dst)
+		if err != nil {
+			return err
+		}
+		if requireExact {
+			if rows.Next() {
+				return pgx.ErrTooManyRows
 			}
-			rows.Close()
+		}
+		rows.Close()
+		return rows.Err()
+	} else {
+		if rows.Err() != nil {
 			return rows.Err()
+		} else if requireExact {
+			return pgx.ErrNoRows
 		} else {
-			if rows.Err() != nil {
-				return rows.Err()
-			} else if requireExact {
-				return pgx.ErrNoRows
-			} else {
-				return nil
-			}
+			return nil
 		}
-	} else {
-		// scanning a single column into a primitive type or a Scanner struct
-		if len(rows.FieldDescriptions()) != 1 {
-			panic(fmt.Errorf("expected a single column with return type %v, got %v", t, rows.FieldDescriptions()))
+	}
+}
+
+// Iterate over the rows of a cursor, scanning each into either a struct (using the mapping defined by db and db_prefix tags)
+// or a single value (for queries returning a single column) without holding the whole result in memory.
+// The cursor is closed when the loop finishes or exits early.
+// Errors are yielded along with a zero value and end the iteration.
+// As the cursor can only be read once, the resulting sequence is single-use.
+func IterRows[T any](rows pgx.Rows) iter.Seq2[T, error] {
+	return IterRowsWithOptions[T](rows, DefaultScanOptions)
+}
+
+// Version of IterRows with explicit options for matching columns to struct fields.
+func IterRowsWithOptions[T any](rows pgx.Rows, opts ScanOptions) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		defer rows.Close()
+		scan, err := rowScannerFor[T](rows, opts)
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
 		}
-		if rows.Next() {
-			err := rows.Scan(dst)
+
+		for rows.Next() {
+			var record T
+			err = scan(&record)
 			if err != nil {
-				return err
+				var zero T
+				yield(zero, err)
+				return
 			}
-			if requireExact {
-				if rows.Next() {
-					return pgx.ErrTooManyRows
-				}
-			}
-			rows.Close()
-			return rows.Err()
-		} else {
-			if rows.Err() != nil {
-				return rows.Err()
-			} else if requireExact {
-				return pgx.ErrNoRows
-			} else {
-				return nil
+			if !yield(record, nil) {
+				return
 			}
 		}
+		if err := rows.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
 	}
 }
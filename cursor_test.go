@@ -0,0 +1,466 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+// In-memory cursor returning text-format values, decoded using the default pgx type map.
+// Rows are converted to raw values up front so that reading them does not allocate.
+type fakeRows struct {
+	typeMap *pgtype.Map
+	fields  []pgconn.FieldDescription
+	raw     [][][]byte
+	row     int
+	closed  bool
+	err     error
+}
+
+var fakeTypeMap = pgtype.NewMap()
+
+type fakeCol struct {
+	name string
+	oid  uint32
+}
+
+func newFakeRows(cols []fakeCol, data ...[]*string) *fakeRows {
+	fields := make([]pgconn.FieldDescription, len(cols))
+	for i, c := range cols {
+		fields[i] = pgconn.FieldDescription{Name: c.name, DataTypeOID: c.oid, Format: pgtype.TextFormatCode}
+	}
+	raw := make([][][]byte, len(data))
+	for i, row := range data {
+		raw[i] = make([][]byte, len(cols))
+		for j, v := range row {
+			if v != nil {
+				raw[i][j] = []byte(*v)
+			}
+		}
+	}
+	return &fakeRows{typeMap: fakeTypeMap, fields: fields, raw: raw, row: -1}
+}
+
+// Rewinds the cursor so that its rows can be read again.
+func (r *fakeRows) reset() {
+	r.row = -1
+	r.closed = false
+}
+
+// Shorthand for a row of non-null text values
+func textRow(vals ...string) []*string {
+	out := make([]*string, len(vals))
+	for i := range vals {
+		out[i] = &vals[i]
+	}
+	return out
+}
+
+func (r *fakeRows) Close()                                       { r.closed = true }
+func (r *fakeRows) Err() error                                   { return r.err }
+func (r *fakeRows) CommandTag() pgconn.CommandTag                { return pgconn.NewCommandTag("SELECT") }
+func (r *fakeRows) FieldDescriptions() []pgconn.FieldDescription { return r.fields }
+func (r *fakeRows) Conn() *pgx.Conn                              { return nil }
+
+func (r *fakeRows) Next() bool {
+	if r.closed || r.err != nil {
+		return false
+	}
+	r.row++
+	if r.row >= len(r.raw) {
+		r.closed = true
+		return false
+	}
+	return true
+}
+
+func (r *fakeRows) RawValues() [][]byte {
+	return r.raw[r.row]
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	if len(dest) != len(r.fields) {
+		return errors.New("wrong number of scan targets")
+	}
+	raw := r.RawValues()
+	for i, d := range dest {
+		if d == nil {
+			continue
+		}
+		err := r.typeMap.Scan(r.fields[i].DataTypeOID, r.fields[i].Format, raw[i], d)
+		if err != nil {
+			return pgx.ScanArgError{ColumnIndex: i, Err: err}
+		}
+	}
+	return nil
+}
+
+func (r *fakeRows) Values() ([]any, error) {
+	raw := r.RawValues()
+	out := make([]any, len(raw))
+	for i := range raw {
+		if raw[i] == nil {
+			continue
+		}
+		t, ok := r.typeMap.TypeForOID(r.fields[i].DataTypeOID)
+		if !ok {
+			out[i] = string(raw[i])
+			continue
+		}
+		v, err := t.Codec.DecodeValue(r.typeMap, r.fields[i].DataTypeOID, r.fields[i].Format, raw[i])
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+var fooCols = []fakeCol{{"a", pgtype.Int8OID}, {"b", pgtype.TextOID}, {"c", pgtype.Float8OID}}
+
+func TestIterRows(t *testing.T) {
+	rows := newFakeRows(fooCols, textRow("1", "a", "1.5"), textRow("2", "b", "2.5"), textRow("3", "c", "3.5"))
+
+	var got []Foo
+	for foo, err := range IterRows[Foo](rows) {
+		assert.NoError(t, err)
+		got = append(got, foo)
+		if len(got) == 2 {
+			break
+		}
+	}
+	assert.True(t, rows.closed, "cursor not closed after early exit")
+	assert.Equal(t, []Foo{{A: 1, B: "a", Bar: Bar{C: 1.5}}, {A: 2, B: "b", Bar: Bar{C: 2.5}}}, got)
+}
+
+func TestIterRowsError(t *testing.T) {
+	rows := newFakeRows(fooCols, textRow("1", "a", "1.5"), textRow("x", "b", "2.5"))
+
+	n := 0
+	var lastErr error
+	for _, err := range IterRows[Foo](rows) {
+		n++
+		lastErr = err
+	}
+	assert.Equal(t, 2, n)
+	assert.Error(t, lastErr)
+	assert.True(t, rows.closed)
+}
+
+// ScanRows as it was before scan plans, looking up pointers for every row.
+func scanRowsPerRowLookup[T any](rows pgx.Rows, dst *[]T) error {
+	defer rows.Close()
+	mapping := structMappingFor[T]()
+	cols := make([]FieldName, len(rows.FieldDescriptions()))
+	for i, fd := range rows.FieldDescriptions() {
+		cols[i] = FieldName(fd.Name)
+	}
+
+	*dst = (*dst)[:0]
+	for rows.Next() {
+		var record T
+		ptrs, err := mapping.extractScanPointers(cols, reflect.ValueOf(&record))
+		if err != nil {
+			return err
+		}
+		err = rows.Scan(ptrs...)
+		if err != nil {
+			return err
+		}
+		*dst = append(*dst, record)
+	}
+	return rows.Err()
+}
+
+func benchmarkRows(n int) [][]*string {
+	data := make([][]*string, n)
+	for i := range data {
+		data[i] = textRow(strconv.Itoa(i), "name", "0.5", "1", "2", "3.5")
+	}
+	return data
+}
+
+var benchCols = []fakeCol{
+	{"a", pgtype.Int8OID}, {"b", pgtype.TextOID}, {"c", pgtype.Float8OID},
+	{"x_a", pgtype.Int8OID}, {"x_b", pgtype.Int4OID}, {"x_bar_c", pgtype.Float8OID},
+}
+
+type benchRecord struct {
+	Foo
+	X struct {
+		A    int64 `db:"a"`
+		B    int32 `db:"b"`
+		Bar2 *Bar  `db_prefix:"bar_"`
+	} `db_prefix:"x_"`
+}
+
+func BenchmarkScanRows(b *testing.B) {
+	rows := newFakeRows(benchCols, benchmarkRows(1000)...)
+	var out []benchRecord
+	b.ReportAllocs()
+	for range b.N {
+		rows.reset()
+		err := ScanRows(rows, &out)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkScanRowsPerRowLookup(b *testing.B) {
+	rows := newFakeRows(benchCols, benchmarkRows(1000)...)
+	var out []benchRecord
+	b.ReportAllocs()
+	for range b.N {
+		rows.reset()
+		err := scanRowsPerRowLookup(rows, &out)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestScanRowsWithPlan(t *testing.T) {
+	cols := []fakeCol{{"bar_c", pgtype.Float8OID}, {"a", pgtype.Int8OID}, {"b", pgtype.TextOID}}
+	var out []Foo2
+	err := ScanRows(newFakeRows(cols, textRow("1.5", "1", "a"), textRow("2.5", "2", "b")), &out)
+	assert.NoError(t, err)
+	assert.Equal(t, []Foo2{{A: 1, B: "a", Bar2: &Bar{C: 1.5}}, {A: 2, B: "b", Bar2: &Bar{C: 2.5}}}, out)
+
+	// unmapped columns are only reported once there is a row to scan
+	cols = append(cols, fakeCol{"d", pgtype.TextOID})
+	err = ScanRows(newFakeRows(cols), &out)
+	assert.NoError(t, err)
+	err = ScanRows(newFakeRows(cols, textRow("1.5", "1", "a", "d")), &out)
+	assert.EqualError(t, err, "missing database field d in struct Foo2")
+}
+
+func TestStrictScan(t *testing.T) {
+	strict := ScanOptions{Strict: true}
+	cols := []fakeCol{{"a", pgtype.Int8OID}, {"d", pgtype.TextOID}, {"e", pgtype.TextOID}}
+	var out []Foo
+	err := ScanRowsWithOptions(newFakeRows(cols), &out, strict)
+	var mismatch *ScanMismatchError
+	if assert.ErrorAs(t, err, &mismatch) {
+		assert.Equal(t, []FieldName{"d", "e"}, mismatch.UnmappedColumns)
+		assert.Equal(t, []FieldName{"b", "c"}, mismatch.UnfilledFields)
+	}
+	assert.EqualError(t, err, "result does not match struct Foo: unmapped columns [d e], unfilled fields [b c]")
+
+	// unfilled fields as warnings
+	var warned *ScanMismatchError
+	strict.WarnUnfilled = func(m *ScanMismatchError) { warned = m }
+	cols = []fakeCol{{"a", pgtype.Int8OID}, {"b", pgtype.TextOID}}
+	var foo Foo
+	err = ScanSingleRowWithOptions(newFakeRows(cols, textRow("1", "a")), &foo, true, strict)
+	assert.NoError(t, err)
+	assert.Equal(t, Foo{A: 1, B: "a"}, foo)
+	if assert.NotNil(t, warned) {
+		assert.Equal(t, []FieldName{"c"}, warned.UnfilledFields)
+	}
+}
+
+func TestPerQueryScanOptions(t *testing.T) {
+	strict := ScanOptions{Strict: true}
+	cols := []fakeCol{{"a", pgtype.Int8OID}, {"b", pgtype.TextOID}}
+	var n int
+	var lastErr error
+	for _, err := range IterRowsWithOptions[Foo](newFakeRows(cols, textRow("1", "a")), strict) {
+		n++
+		lastErr = err
+	}
+	assert.Equal(t, 1, n)
+	assert.EqualError(t, lastErr, "result does not match struct Foo: unfilled fields [c]")
+
+	// options are not sent as parameters
+	batch := NewBatch()
+	var out []Foo
+	QueueQueryWithOptions(batch, &out, strict, "SELECT * FROM foo WHERE a = $1", 1)
+	QueueNamedQueryWithOptions(batch, &out, strict, "SELECT * FROM foo WHERE b = @b", Foo{B: "x"})
+	assert.Equal(t, []any{1}, batch.QueuedQueries[0].Arguments)
+	assert.Equal(t, SQL("SELECT * FROM foo WHERE b = $1"), SQL(batch.QueuedQueries[1].SQL))
+	assert.Equal(t, []any{"x"}, batch.QueuedQueries[1].Arguments)
+}
+
+func TestIgnoreExtraColumns(t *testing.T) {
+	lenient := ScanOptions{IgnoreExtraColumns: true}
+	cols := []fakeCol{
+		{"a", pgtype.Int8OID}, {"x", pgtype.Int4OID}, {"b", pgtype.TextOID},
+		{"y", pgtype.JSONBOID}, {"c", pgtype.Float8OID}, {"z", pgtype.TimestamptzOID},
+	}
+	var out []Foo
+	err := ScanRowsWithOptions(newFakeRows(cols, textRow("1", "7", "a", `{"k": 1}`, "1.5", "2025-01-01 00:00:00Z")), &out, lenient)
+	assert.NoError(t, err)
+	assert.Equal(t, []Foo{{A: 1, B: "a", Bar: Bar{C: 1.5}}}, out)
+
+	// extra columns are not reported by strict mode either
+	lenient.Strict = true
+	var foo Foo
+	err = ScanSingleRowWithOptions(newFakeRows(cols, textRow("2", "7", "b", "null", "2.5", "2025-01-01 00:00:00Z")), &foo, true, lenient)
+	assert.NoError(t, err)
+	assert.Equal(t, Foo{A: 2, B: "b", Bar: Bar{C: 2.5}}, foo)
+}
+
+type optionalInner struct {
+	D *string `db:"d"`
+	E int     `db:"e"`
+}
+
+type optionalOuter struct {
+	C     float64        `db:"c"`
+	Inner *optionalInner `db_prefix:"in_"`
+}
+
+type withOptionals struct {
+	A     int            `db:"a"`
+	Outer *optionalOuter `db_prefix:"out_"`
+}
+
+func TestScanOptionalStructs(t *testing.T) {
+	cols := []fakeCol{{"a", pgtype.Int8OID}, {"out_c", pgtype.Float8OID}, {"out_in_d", pgtype.TextOID}, {"out_in_e", pgtype.Int4OID}}
+	d := "d"
+	rows := newFakeRows(cols,
+		[]*string{textRow("1")[0], nil, nil, nil},
+		[]*string{textRow("2")[0], textRow("2.5")[0], nil, nil},
+		textRow("3", "3.5", "d", "3"),
+		[]*string{textRow("4")[0], textRow("4.5")[0], nil, textRow("4")[0]},
+	)
+	var out []withOptionals
+	err := ScanRows(rows, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, []withOptionals{
+		{A: 1},
+		{A: 2, Outer: &optionalOuter{C: 2.5}},
+		{A: 3, Outer: &optionalOuter{C: 3.5, Inner: &optionalInner{D: &d, E: 3}}},
+		{A: 4, Outer: &optionalOuter{C: 4.5, Inner: &optionalInner{E: 4}}},
+	}, out)
+
+	// existing pointers are cleared or replaced, leaving the structs they pointed to untouched
+	outer := &optionalOuter{C: 1, Inner: &optionalInner{E: 1}}
+	dst := withOptionals{Outer: outer}
+	err = ScanSingleRow(newFakeRows(cols, []*string{textRow("5")[0], nil, nil, nil}), &dst, true)
+	assert.NoError(t, err)
+	assert.Equal(t, withOptionals{A: 5}, dst)
+	dst.Outer = outer
+	err = ScanSingleRow(newFakeRows(cols, []*string{textRow("6")[0], textRow("6.5")[0], nil, nil}), &dst, true)
+	assert.NoError(t, err)
+	assert.Equal(t, withOptionals{A: 6, Outer: &optionalOuter{C: 6.5}}, dst)
+	assert.Equal(t, &optionalOuter{C: 1, Inner: &optionalInner{E: 1}}, outer)
+
+	// NULL in a non-nullable field of a non-null struct
+	err = ScanRows(newFakeRows(cols, []*string{textRow("7")[0], textRow("7.5")[0], textRow("d")[0], nil}), &out)
+	assert.ErrorContains(t, err, "cannot scan NULL into *int")
+}
+
+type withOptionalScanner struct {
+	A    int `db:"a"`
+	Note *struct {
+		T    pgtype.Text `db:"t"`
+		Tags []string    `db:"tags"`
+	} `db_prefix:"note_"`
+}
+
+func TestScanOptionalScanner(t *testing.T) {
+	// NULL is taken from the row rather than the zero values of Scanners and slices, so empty values are kept
+	cols := []fakeCol{{"a", pgtype.Int8OID}, {"note_t", pgtype.TextOID}, {"note_tags", pgtype.TextArrayOID}}
+	var out []withOptionalScanner
+	err := ScanRows(newFakeRows(cols,
+		[]*string{textRow("1")[0], nil, textRow("{}")[0]},
+		[]*string{textRow("2")[0], textRow("")[0], nil},
+		[]*string{textRow("3")[0], nil, nil},
+	), &out)
+	assert.NoError(t, err)
+	if assert.Len(t, out, 3) && assert.NotNil(t, out[0].Note) && assert.NotNil(t, out[1].Note) {
+		assert.Equal(t, pgtype.Text{}, out[0].Note.T)
+		assert.Equal(t, []string{}, out[0].Note.Tags)
+		assert.Equal(t, pgtype.Text{String: "", Valid: true}, out[1].Note.T)
+		assert.Nil(t, out[1].Note.Tags)
+		assert.Nil(t, out[2].Note)
+	}
+}
+
+type RowMeta struct {
+	Note *string `db:"note"`
+}
+
+type withEmbeddedPointer struct {
+	ID int `db:"id"`
+	*RowMeta
+}
+
+func TestScanEmbeddedPointer(t *testing.T) {
+	// without db_prefix, embedded pointers are always allocated so that promoted fields can be used
+	cols := []fakeCol{{"id", pgtype.Int8OID}, {"note", pgtype.TextOID}}
+	var out []withEmbeddedPointer
+	err := ScanRows(newFakeRows(cols, []*string{textRow("1")[0], nil}), &out)
+	assert.NoError(t, err)
+	if assert.Len(t, out, 1) && assert.NotNil(t, out[0].RowMeta) {
+		assert.Nil(t, out[0].Note)
+	}
+}
+
+func TestExtractNamedArgsFromNilPointer(t *testing.T) {
+	_, args := ExtractNamedQuery("SELECT @a, @bar_c", Foo2{A: 1})
+	assert.Equal(t, []any{1, nil}, args)
+}
+
+func TestScanDynamicRecords(t *testing.T) {
+	cols := []fakeCol{{"b", pgtype.TextOID}, {"a", pgtype.Int8OID}}
+	data := [][]*string{textRow("x", "1"), {nil, textRow("2")[0]}}
+
+	var maps []map[string]any
+	err := ScanRows(newFakeRows(cols, data...), &maps)
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]any{{"a": int64(1), "b": "x"}, {"a": int64(2), "b": nil}}, maps)
+
+	var records []Record
+	err = ScanRows(newFakeRows(cols, data...), &records)
+	assert.NoError(t, err)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, []string{"b", "a"}, records[0].Names())
+		assert.Equal(t, []any{"x", int64(1)}, records[0].Values)
+		assert.Equal(t, uint32(pgtype.Int8OID), records[1].Fields[1].DataTypeOID)
+		v, ok := records[1].Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, int64(2), v)
+	}
+
+	var rec *Record
+	err = ScanSingleRow(newFakeRows(cols, data...), &rec, false)
+	assert.NoError(t, err)
+	if assert.NotNil(t, rec) {
+		assert.Equal(t, []any{"x", int64(1)}, rec.Values)
+	}
+}
+
+func TestScanRowsKeyed(t *testing.T) {
+	data := [][]*string{textRow("1", "a", "1.5"), textRow("2", "b", "2.5"), textRow("1", "c", "3.5")}
+
+	var byB map[string]*Foo
+	err := ScanRowsMap(newFakeRows(fooCols, data...), &byB, KeyColumn[string, *Foo]("b"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]*Foo{
+		"a": {A: 1, B: "a", Bar: Bar{C: 1.5}},
+		"b": {A: 2, B: "b", Bar: Bar{C: 2.5}},
+		"c": {A: 1, B: "c", Bar: Bar{C: 3.5}},
+	}, byB)
+
+	var byA map[int][]Foo
+	err = ScanRowsGroupBy(newFakeRows(fooCols, data...), &byA, KeyColumn[int, Foo]("a"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[int][]Foo{
+		1: {{A: 1, B: "a", Bar: Bar{C: 1.5}}, {A: 1, B: "c", Bar: Bar{C: 3.5}}},
+		2: {{A: 2, B: "b", Bar: Bar{C: 2.5}}},
+	}, byA)
+
+	assert.Panics(t, func() { KeyColumn[string, Foo]("a") })
+}
@@ -1,12 +1,23 @@
 // Pgxx is high-level client providing cursor-struct mapping for Postgres using pgx.
 //
-// Basic functionality is provided by [Exec], [Query], [QueryOne], and [QueryExactlyOne] (which use queries with positional parameters) and their Named counterparts (which use named parameters extracted from a struct).
+// Basic functionality is provided by [Exec], [Query], [QueryOne], and [QueryExactlyOne] (which use queries with positional parameters) and their Named counterparts (which use named parameters extracted from a struct, a map, or several of these combined with [MergeArgs]).
+//
+// Results too large to hold in memory can be streamed using [QueryIter] and [NamedQueryIter].
+// Queries whose columns are not known at compile time can be read into [Record] or map[string]any.
+// Large tables can be read one page at a time using keyset pagination with [Paginate],
+// which returns opaque tokens for the adjacent pages.
 //
 // In order to prevent accidental injection, all queries use the [SQL] type (compatible with standard string literals).
 // In order to more easily list fields in queries, this package contains
 // a number of helper functions to format lists of fields in various contexts (such as [ListFields])
 // as well as the [DBFields] function to get the lase of mapable fields for a given go type.
 //
+// Statements run many times on the same connection can be prepared with [PrepareNamed].
+// Named queries, struct mappings, and scan plans are cached after their first use,
+// which can be monitored with [GetCacheStats] and cleared with [ResetCaches].
+// The number of named queries and scan plans cached is limited,
+// and can be changed with [SetQueryCacheSize] and [SetScanPlanCacheSize].
+//
 // For ACID transactions use [RunInTx], which provides collision detection and a client-side retry loop.
 // If all queries areindependent of each other, the entire transaction may be run in a single round-trip using the batch API,
 // accessed through [NewBatch], [RunBatch] and the various Queue functions (such as [QueueQuery]).
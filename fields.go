@@ -3,6 +3,8 @@
 
 package pgxx
 
+import "errors"
+
 type FieldName string
 
 // Produces a list of fields comma-separated for use in queries
@@ -47,3 +49,76 @@ func ListFieldsWithPrefix(fields []FieldName, queryPrefix SQL, resultPrefix SQL)
 func NamedInsertQuery(tableName SQL, fields []FieldName) SQL {
 	return "INSERT INTO " + tableName + " (" + ListFields(fields) + ") VALUES (" + ListNamedFieldParams(fields) + ")"
 }
+
+// Produces a list of assignments of fields to named parameters matching the field names (as in `a = @a, b = @b`)
+func ListNamedFieldAssignments(fields []FieldName) SQL {
+	if len(fields) == 0 {
+		return ""
+	}
+	out := SQL(fields[0]) + " = @" + SQL(fields[0])
+	for _, f := range fields[1:] {
+		out += ", " + SQL(f) + " = @" + SQL(f)
+	}
+	return out
+}
+
+// Produces an UPDATE query setting the fields in setFields to named parameters matching the field names,
+// for rows matching all of keyFields (such as those returned by KeyFields).
+// Panics if keyFields is empty, as the query would update every row,
+// or if setFields is empty, as the query would not be valid SQL.
+func NamedUpdateQuery(tableName SQL, setFields []FieldName, keyFields []FieldName) SQL {
+	if len(keyFields) == 0 {
+		panic(errors.New("update query requires at least one key field"))
+	}
+	if len(setFields) == 0 {
+		panic(errors.New("update query requires at least one field to set"))
+	}
+	out := "UPDATE " + tableName + " SET " + ListNamedFieldAssignments(setFields)
+	for i, f := range keyFields {
+		if i == 0 {
+			out += " WHERE "
+		} else {
+			out += " AND "
+		}
+		out += SQL(f) + " = @" + SQL(f)
+	}
+	return out
+}
+
+// Produces an INSERT query (like NamedInsertQuery) which instead updates the fields in updateFields
+// (to the values which would have been inserted) if a row conflicting on conflictFields already exists.
+// Does nothing on conflict if updateFields is empty.
+// Panics if updateFields is given without conflictFields, which Postgres requires for DO UPDATE.
+func NamedUpsertQuery(tableName SQL, fields []FieldName, conflictFields []FieldName, updateFields []FieldName) SQL {
+	if len(updateFields) == 0 {
+		return NamedInsertOrIgnoreQuery(tableName, fields, conflictFields)
+	} else if len(conflictFields) == 0 {
+		panic(errors.New("upsert query requires at least one conflict field to update on conflict"))
+	}
+	out := NamedInsertQuery(tableName, fields) + " ON CONFLICT (" + ListFields(conflictFields) + ") DO UPDATE SET "
+	for i, f := range updateFields {
+		if i > 0 {
+			out += ", "
+		}
+		out += SQL(f) + " = EXCLUDED." + SQL(f)
+	}
+	return out
+}
+
+// Produces an INSERT query (like NamedInsertQuery) which skips rows conflicting on conflictFields
+// (or on any unique constraint if conflictFields is empty).
+func NamedInsertOrIgnoreQuery(tableName SQL, fields []FieldName, conflictFields []FieldName) SQL {
+	out := NamedInsertQuery(tableName, fields) + " ON CONFLICT"
+	if len(conflictFields) > 0 {
+		out += " (" + ListFields(conflictFields) + ")"
+	}
+	return out + " DO NOTHING"
+}
+
+// Produces a RETURNING clause (with a leading space) to append to a query, or nothing if there are no fields.
+func Returning(fields []FieldName) SQL {
+	if len(fields) == 0 {
+		return ""
+	}
+	return " RETURNING " + ListFields(fields)
+}
@@ -0,0 +1,43 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type keyedFoo struct {
+	ID      int    `db:"id,pk,generated"`
+	Tenant  string `db:"tenant,pk"`
+	Name    string `db:"name"`
+	Created string `db:"created,insertonly"`
+}
+
+func TestQueryBuilders(t *testing.T) {
+	assert.Equal(t, SQL("UPDATE foo SET name = @name WHERE id = @id AND tenant = @tenant"),
+		NamedUpdateQuery("foo", UpdatableFields[keyedFoo](), KeyFields[keyedFoo]()))
+
+	assert.Equal(t, SQL("INSERT INTO foo (tenant, name, created) VALUES (@tenant, @name, @created) "+
+		"ON CONFLICT (tenant, name) DO UPDATE SET name = EXCLUDED.name RETURNING id"),
+		NamedUpsertQuery("foo", InsertableFields[keyedFoo](), []FieldName{"tenant", "name"}, UpdatableFields[keyedFoo]())+
+			Returning([]FieldName{"id"}))
+
+	assert.Equal(t, SQL("INSERT INTO foo (name) VALUES (@name) ON CONFLICT (name) DO NOTHING"),
+		NamedUpsertQuery("foo", []FieldName{"name"}, []FieldName{"name"}, nil))
+	assert.Equal(t, SQL("INSERT INTO foo (name) VALUES (@name) ON CONFLICT DO NOTHING"),
+		NamedInsertOrIgnoreQuery("foo", []FieldName{"name"}, nil)+Returning(nil))
+
+	// queries which would update every row or are not valid SQL
+	assert.PanicsWithError(t, "update query requires at least one key field", func() {
+		NamedUpdateQuery("foo", UpdatableFields[Foo](), KeyFields[Foo]())
+	})
+	assert.PanicsWithError(t, "update query requires at least one field to set", func() {
+		NamedUpdateQuery("foo", nil, []FieldName{"a"})
+	})
+	assert.PanicsWithError(t, "upsert query requires at least one conflict field to update on conflict", func() {
+		NamedUpsertQuery("foo", []FieldName{"name"}, nil, []FieldName{"name"})
+	})
+}
@@ -0,0 +1,223 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// A piece of SQL along with the values of its positional parameters ($1, $2, etc. numbered within the fragment),
+// used to build dynamic queries. When fragments are combined, their parameters are renumbered to match.
+//
+// A Fragment can be passed as the first argument of Query, Exec, and the Queue functions (as a pgx.QueryRewriter),
+// in which case it is appended to the query string (which may be empty), as in
+//
+//	Query[User](ctx, conn, "SELECT * FROM users", Where(And(Optional("name = $1", nameFilter), Frag("active"))))
+type Fragment struct {
+	SQL  SQL
+	Args []any
+	// SQL split into text and positional parameters, so that fragments are not parsed again when combined.
+	// Nil for fragments written as literals, which are parsed when needed.
+	parts []queryPart
+}
+
+// Fragments are parsed without going through the query cache,
+// as they are mostly generated dynamically and would evict the hardcoded queries it is meant for.
+func makeFragment(sql SQL, args []any) (Fragment, error) {
+	parsed := parseNamedQuery(sql)
+	if len(parsed.fields) > 0 {
+		return Fragment{}, fmt.Errorf("fragment uses named parameter @%s, which requires NamedFrag", parsed.fields[0])
+	}
+	if parsed.maxOrdinal != len(args) {
+		return Fragment{}, fmt.Errorf("fragment uses %d positional parameters, but %d were given", parsed.maxOrdinal, len(args))
+	}
+	return Fragment{SQL: sql, Args: args, parts: parsed.parts}, nil
+}
+
+// Creates a fragment from SQL using positional parameters.
+// Panics if the number of arguments does not match the highest parameter used, or if it uses named parameters.
+func Frag(sql SQL, args ...any) Fragment {
+	f, err := makeFragment(sql, args)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// Creates a fragment from SQL using named parameters, pulling them out of a struct or map
+// in the same way as [ExtractNamedQuery].
+func NamedFrag(namedSQL SQL, argsStruct any) Fragment {
+	sql, args := ExtractNamedQuery(namedSQL, argsStruct)
+	return Frag(sql, args...)
+}
+
+// Creates a fragment of SQL without parameters, which does not need to be parsed.
+func textFrag(sql SQL) Fragment {
+	return Fragment{SQL: sql, parts: []queryPart{{text: string(sql), field: -1}}}
+}
+
+// Whether the fragment has no SQL, such as an omitted optional clause.
+func (f Fragment) IsEmpty() bool {
+	return strings.TrimSpace(string(f.SQL)) == ""
+}
+
+// Returns the parts of a fragment, parsing it if it was written as a literal
+// (in which case any named parameters are kept as text for later rewriting).
+func (f Fragment) partsOf() []queryPart {
+	if f.parts != nil {
+		return f.parts
+	}
+	parsed := parseNamedQuery(f.SQL)
+	parts := make([]queryPart, len(parsed.parts))
+	for i, p := range parsed.parts {
+		if p.field >= 0 {
+			p.text = "@" + string(parsed.fields[p.field])
+			if p.expand {
+				p.text += "..."
+			}
+			p.field, p.expand = -1, false
+		}
+		parts[i] = p
+	}
+	return parts
+}
+
+// Joins fragments with a separator, skipping empty ones and renumbering parameters.
+func Join(sep SQL, frags ...Fragment) Fragment {
+	var out Fragment
+	var sb strings.Builder
+	for _, f := range frags {
+		if f.IsEmpty() {
+			continue
+		}
+		if sb.Len() > 0 && sep != "" {
+			sb.WriteString(string(sep))
+			out.parts = append(out.parts, queryPart{text: string(sep), field: -1})
+		}
+		offset := len(out.Args)
+		for _, p := range f.partsOf() {
+			if p.ordinal > 0 && offset > 0 {
+				p.ordinal += offset
+				p.text = "$" + strconv.Itoa(p.ordinal)
+			}
+			sb.WriteString(p.text)
+			out.parts = append(out.parts, p)
+		}
+		out.Args = append(out.Args, f.Args...)
+	}
+	out.SQL = SQL(sb.String())
+	return out
+}
+
+// Joins fragments with spaces, skipping empty ones.
+func Concat(frags ...Fragment) Fragment {
+	return Join(" ", frags...)
+}
+
+// Combines conditions so that all must hold, skipping empty ones.
+// Returns an empty fragment if all are empty.
+func And(conds ...Fragment) Fragment {
+	return joinConditions(" AND ", conds)
+}
+
+// Combines conditions so that any must hold, skipping empty ones.
+// Returns an empty fragment if all are empty.
+func Or(conds ...Fragment) Fragment {
+	return joinConditions(" OR ", conds)
+}
+
+func joinConditions(op SQL, conds []Fragment) Fragment {
+	var wrapped []Fragment
+	for _, c := range conds {
+		if !c.IsEmpty() {
+			wrapped = append(wrapped, Join("", textFrag("("), c, textFrag(")")))
+		}
+	}
+	if len(wrapped) == 1 {
+		return wrapped[0]
+	}
+	return Join(op, wrapped...)
+}
+
+// Creates a condition that column is one of the elements of values (which must be a slice or array).
+// An empty slice produces FALSE rather than an empty or NULL list, so that the condition stays correct when negated
+// (unlike expanded named parameters, where the surrounding IN or NOT IN is not known).
+func In(column SQL, values any) Fragment {
+	v := reflect.ValueOf(values)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		panic(fmt.Errorf("values of In must be a slice, got %T", values))
+	}
+	if v.Len() == 0 {
+		return textFrag("FALSE")
+	}
+	out := Fragment{Args: make([]any, v.Len())}
+	sb := strings.Builder{}
+	open := string(column) + " IN ("
+	sb.WriteString(open)
+	out.parts = append(out.parts, queryPart{text: open, field: -1})
+	for i := range v.Len() {
+		if i > 0 {
+			sb.WriteString(", ")
+			out.parts = append(out.parts, queryPart{text: ", ", field: -1})
+		}
+		param := "$" + strconv.Itoa(i+1)
+		sb.WriteString(param)
+		out.parts = append(out.parts, queryPart{text: param, field: -1, ordinal: i + 1})
+		out.Args[i] = v.Index(i).Interface()
+	}
+	sb.WriteString(")")
+	out.parts = append(out.parts, queryPart{text: ")", field: -1})
+	out.SQL = SQL(sb.String())
+	return out
+}
+
+// Creates a fragment with a single parameter ($1) which is omitted (empty) if value is nil or a nil pointer,
+// for optional filters such as `Optional("name = $1", filter.Name)`.
+func Optional(sql SQL, value any) Fragment {
+	if value == nil {
+		return Fragment{}
+	}
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Pointer && v.IsNil() {
+		return Fragment{}
+	}
+	return Frag(sql, value)
+}
+
+// Returns the fragment if cond is true, or an empty fragment otherwise.
+func If(cond bool, f Fragment) Fragment {
+	if !cond {
+		return Fragment{}
+	}
+	return f
+}
+
+// Prefixes a condition with WHERE, or returns an empty fragment if the condition is empty.
+func Where(cond Fragment) Fragment {
+	if cond.IsEmpty() {
+		return Fragment{}
+	}
+	return Concat(textFrag("WHERE"), cond)
+}
+
+// Renders the fragment as a query with positional parameters, for use with functions taking them separately.
+func (f Fragment) Render() (SQL, []any) {
+	return f.SQL, f.Args
+}
+
+// Implements pgx.QueryRewriter, appending the fragment to the query it is passed with
+// (whose own positional parameters are given by any following arguments).
+func (f Fragment) RewriteQuery(ctx context.Context, conn *pgx.Conn, sql string, args []any) (string, []any, error) {
+	prefix, err := makeFragment(SQL(sql), args)
+	if err != nil {
+		return "", nil, err
+	}
+	out := Concat(prefix, f)
+	return string(out.SQL), out.Args, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFragments(t *testing.T) {
+	var noName *string
+	minAge := 21
+	filter := And(
+		Optional("name = $1", noName),
+		Optional("age >= $1", &minAge),
+		Or(In("role", []string{"admin", "owner"}), Frag("created_by = $1 OR updated_by = $1", 7)),
+		NamedFrag("tenant = @a AND '$1' <> @b", Foo{A: 3, B: "x"}),
+	)
+	query, args := Concat(Frag("SELECT * FROM users"), Where(filter), Frag("LIMIT $1", 10)).Render()
+	assert.Equal(t, SQL("SELECT * FROM users WHERE (age >= $1) AND "+
+		"((role IN ($2, $3)) OR (created_by = $4 OR updated_by = $4)) AND "+
+		"(tenant = $5 AND '$1' <> $6) LIMIT $7"), query)
+	assert.Equal(t, []any{&minAge, "admin", "owner", 7, 3, "x", 10}, args)
+
+	// empty conditions disappear
+	query, args = Concat(Frag("SELECT * FROM users"), Where(And(Optional("name = $1", nil), If(false, Frag("active"))))).Render()
+	assert.Equal(t, SQL("SELECT * FROM users"), query)
+	assert.Empty(t, args)
+	assert.Equal(t, SQL("FALSE"), In("id", []int{}).SQL)
+	// negating an empty list matches every row, as NOT IN () would
+	query, args = Concat(Frag("SELECT * FROM users WHERE NOT"), In("id", []int{})).Render()
+	assert.Equal(t, SQL("SELECT * FROM users WHERE NOT FALSE"), query)
+	assert.Empty(t, args)
+
+	// fragments written as literals are parsed when joined, keeping named parameters as text
+	query, args = Or(Frag("a = $1", 1), Fragment{SQL: "b = $1 AND c = @c...", Args: []any{2}}).Render()
+	assert.Equal(t, SQL("(a = $1) OR (b = $2 AND c = @c...)"), query)
+	assert.Equal(t, []any{1, 2}, args)
+
+	// nesting renumbers parameters at each level
+	query, args = And(Or(Frag("a = $1", 1), And(Frag("b = $1", 2), Frag("c = $1", 3))), Frag("d = $1", 4)).Render()
+	assert.Equal(t, SQL("((a = $1) OR ((b = $2) AND (c = $3))) AND (d = $4)"), query)
+	assert.Equal(t, []any{1, 2, 3, 4}, args)
+
+	assert.PanicsWithError(t, "fragment uses 2 positional parameters, but 1 were given", func() { Frag("a = $1 AND b = $2", 1) })
+	assert.PanicsWithError(t, "fragment uses named parameter @a, which requires NamedFrag", func() { Frag("a = @a AND b = $1", 1) })
+}
+
+func TestFragmentQueryRewriter(t *testing.T) {
+	// positional parameters of the query come first
+	sql, args, err := Frag("a = $1", 1).RewriteQuery(context.Background(), nil, "SELECT * FROM foo WHERE b = $1 AND", []any{"b"})
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM foo WHERE b = $1 AND a = $2", sql)
+	assert.Equal(t, []any{"b", 1}, args)
+
+	_, _, err = Frag("a = $1", 1).RewriteQuery(context.Background(), nil, "SELECT $2", nil)
+	assert.EqualError(t, err, "fragment uses 2 positional parameters, but 0 were given")
+
+	// dynamic fragments are not cached
+	ResetCaches()
+	_, _, err = Where(And(Frag("a = $1", 1), In("b", []int{2, 3}))).RewriteQuery(context.Background(), nil, "SELECT * FROM foo", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, CacheStats{}, GetCacheStats())
+}
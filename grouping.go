@@ -0,0 +1,293 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Plan for scanning the rows of a join into a tree of structs,
+// where child records are collected into slice fields tagged with db_children.
+type groupPlan struct {
+	levels   []*groupLevel // parents before children
+	columns  []FieldName
+	err      error // deferred until the first row is scanned
+	mismatch *ScanMismatchError
+}
+
+// One struct type in the tree of a grouped scan.
+type groupLevel struct {
+	recordType reflect.Type // struct type of records
+	isPtr      bool         // whether records are held in the slice by pointer
+	prefix     string       // prefix of this level's columns in the result
+	parent     int          // -1 for the root
+	slicePath  []int        // path to the slice holding this level's records within the parent's record
+	plan       *scanPlan
+	keyPaths   [][]int // paths of the fields identifying a record within its parent
+}
+
+func makeGroupPlan(t reflect.Type, fields []FieldName) (*groupPlan, error) {
+	g := &groupPlan{columns: fields}
+	err := g.addLevel(t, "", -1, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// assign each column to the level with the longest matching prefix
+	localNames := make([][]FieldName, len(g.levels))
+	for l := range g.levels {
+		localNames[l] = make([]FieldName, len(fields))
+	}
+	var unmapped []FieldName
+	for i, c := range fields {
+		best := -1
+		for l, lvl := range g.levels {
+			local, hasPrefix := strings.CutPrefix(string(c), lvl.prefix)
+			if !hasPrefix {
+				continue
+			}
+			if _, found := structMappingOf(lvl.recordType).FieldMappings[FieldName(local)]; !found {
+				continue
+			}
+			if best < 0 || len(lvl.prefix) > len(g.levels[best].prefix) {
+				best = l
+			}
+		}
+		if best < 0 {
+			if g.err == nil {
+				g.err = fmt.Errorf("missing database field %s in struct %s", c, t.Name())
+			}
+			unmapped = append(unmapped, c)
+			continue
+		}
+		localNames[best][i] = c[len(g.levels[best].prefix):]
+	}
+
+	var unfilled []FieldName
+	for l, lvl := range g.levels {
+		mapping := structMappingOf(lvl.recordType)
+		filled := make(map[FieldName]bool)
+		for _, c := range localNames[l] {
+			filled[c] = true
+		}
+		for _, f := range mapping.FieldList {
+			if !filled[f] {
+				unfilled = append(unfilled, FieldName(lvl.prefix)+f)
+			}
+		}
+
+		lvl.plan = makePartialScanPlan(lvl.recordType, localNames[l], l > 0)
+
+		keys := mapping.filterFields([]string{"pk"}, false)
+		if len(keys) == 0 {
+			// records without declared keys are identified by all of their columns
+			for _, c := range localNames[l] {
+				if c != "" {
+					keys = append(keys, c)
+				}
+			}
+		}
+		for _, k := range keys {
+			if !filled[k] {
+				return nil, fmt.Errorf("key field %s%s of struct %s missing from result", lvl.prefix, k, lvl.recordType.Name())
+			}
+			lvl.keyPaths = append(lvl.keyPaths, mapping.FieldMappings[k])
+		}
+	}
+
+	if unmapped != nil || unfilled != nil {
+		g.mismatch = &ScanMismatchError{
+			StructType:      t,
+			UnmappedColumns: unmapped,
+			UnfilledFields:  unfilled,
+		}
+	}
+	return g, nil
+}
+
+// Gets the cached group plan for a struct type and the columns of a result set.
+func groupPlanOf(t reflect.Type, fields []pgconn.FieldDescription) (*groupPlan, error) {
+	key := scanPlanKey{kind: groupPlanKind, t: t, columns: columnsKey(fields)}
+	return cachedPlanOf(key, func() (*groupPlan, error) {
+		return makeGroupPlan(t, columnNames(fields))
+	})
+}
+
+func (g *groupPlan) addLevel(t reflect.Type, prefix string, parent int, slicePath []int) error {
+	isPtr := t.Kind() == reflect.Pointer
+	t = derefType(t)
+	if !isMappable(t) {
+		return fmt.Errorf("cannot group rows into non-struct %s", t.Name())
+	}
+	l := len(g.levels)
+	g.levels = append(g.levels, &groupLevel{
+		recordType: t,
+		isPtr:      isPtr,
+		prefix:     prefix,
+		parent:     parent,
+		slicePath:  slicePath,
+	})
+	for i := range t.NumField() {
+		f := t.Field(i)
+		childPrefix, isChildren := f.Tag.Lookup("db_children")
+		if !isChildren || !f.IsExported() {
+			continue
+		}
+		if f.Type.Kind() != reflect.Slice {
+			return fmt.Errorf("db_children field %s of struct %s is not a slice", f.Name, t.Name())
+		}
+		err := g.addLevel(f.Type.Elem(), prefix+childPrefix, l, []int{i})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Per-level state of a grouped scan.
+type groupCursor struct {
+	state     *scanState
+	scratch   reflect.Value // record each row is scanned into before being added to the tree
+	current   reflect.Value // last record added, addressable
+	key       []any         // key of the current record
+	gen       int           // incremented each time a new record is added
+	parentGen int           // generation of the parent when the current record was added
+	present   bool          // whether this level was non-null in the current row
+}
+
+// Scans all rows of a cursor into dst (a pointer to a slice of structs or struct pointers)
+// grouping consecutive rows with the same key into child records.
+func (g *groupPlan) scanAll(rows pgx.Rows, dst reflect.Value, opts ScanOptions) error {
+	err := checkMismatch(g.mismatch, opts)
+	if err != nil {
+		return err
+	}
+
+	// columns without a level are left with nil scan targets, which are skipped
+	ptrs := make([]any, len(g.columns))
+	cursors := make([]*groupCursor, len(g.levels))
+	for l, lvl := range g.levels {
+		cursors[l] = &groupCursor{
+			state:   lvl.plan.newScanState(ptrs),
+			scratch: reflect.New(lvl.recordType).Elem(),
+		}
+	}
+
+	out := dst.Elem()
+	out.SetLen(0)
+	for rows.Next() {
+		if g.err != nil && !opts.IgnoreExtraColumns {
+			return g.err
+		}
+		raw := rows.RawValues()
+		for _, c := range cursors {
+			c.scratch.SetZero()
+			c.present = c.state.prepare(ptrs, c.scratch, raw)
+		}
+		err := rows.Scan(ptrs...)
+		if err != nil {
+			return err
+		}
+
+		for l, lvl := range g.levels {
+			c := cursors[l]
+			if lvl.parent >= 0 && !cursors[lvl.parent].present {
+				c.present = false
+			}
+			if !c.present {
+				continue
+			}
+			err := c.state.finish(c.scratch)
+			if err != nil {
+				return err
+			}
+
+			key := make([]any, len(lvl.keyPaths))
+			for k, path := range lvl.keyPaths {
+				kval, err := c.scratch.FieldByIndexErr(path)
+				if err == nil {
+					key[k] = kval.Interface()
+				}
+			}
+			parentGen := 0
+			if lvl.parent >= 0 {
+				parentGen = cursors[lvl.parent].gen
+			}
+			if c.gen > 0 && c.parentGen == parentGen && reflect.DeepEqual(key, c.key) {
+				continue
+			}
+
+			// add a new record to the parent (or the output)
+			slice := out
+			if lvl.parent >= 0 {
+				slice = fieldByPath(cursors[lvl.parent].current, lvl.slicePath)
+			}
+			record := c.scratch
+			if lvl.isPtr {
+				record = reflect.New(lvl.recordType)
+				record.Elem().Set(c.scratch)
+			}
+			slice.Set(reflect.Append(slice, record))
+			c.current = slice.Index(slice.Len() - 1)
+			if lvl.isPtr {
+				c.current = c.current.Elem()
+			}
+			c.key = key
+			c.gen++
+			c.parentGen = parentGen
+		}
+	}
+	return rows.Err()
+}
+
+// Scan rows of a join to a slice of structs, grouping consecutive rows with the same key into one record.
+//
+// Struct fields of slice type tagged with `db_children:"prefix"` are filled with child records taken from
+// the columns starting with that prefix, and may themselves have children (with prefixes accumulating).
+// Only fields declared directly in the struct of each level are checked for db_children,
+// not those inside its embedded or db_prefix structs.
+// Records are identified by their fields tagged with the pk option (such as `db:"id,pk"`),
+// or all of their columns if there are none, so rows must be ordered by the keys of each level.
+// Child records whose columns are all NULL (as in a LEFT JOIN with no match) are skipped.
+func ScanRowsGrouped[T any](rows pgx.Rows, dst *[]T) error {
+	return ScanRowsGroupedWithOptions(rows, dst, DefaultScanOptions)
+}
+
+// Version of ScanRowsGrouped with explicit options for matching columns to struct fields.
+func ScanRowsGroupedWithOptions[T any](rows pgx.Rows, dst *[]T, opts ScanOptions) error {
+	defer rows.Close()
+	plan, err := groupPlanOf(reflect.TypeFor[T](), rows.FieldDescriptions())
+	if err != nil {
+		return err
+	}
+	return plan.scanAll(rows, reflect.ValueOf(dst), opts)
+}
+
+// Run a query with positional parameters and read out the results as a slice of structs,
+// grouping rows into nested slices of child records as in [ScanRowsGrouped].
+func QueryGrouped[T any](ctx context.Context, conn PoolOrTx, query SQL, args ...any) ([]T, error) {
+	cursor, err := conn.Query(ctx, string(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	var out []T
+	err = ScanRowsGrouped(cursor, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Run a query with named parameters (pulling them out of a struct) and read out the results as a slice of structs,
+// grouping rows into nested slices of child records as in [ScanRowsGrouped].
+func NamedQueryGrouped[T any](ctx context.Context, conn PoolOrTx, namedQuery SQL, argsStruct any) ([]T, error) {
+	query, args := ExtractNamedQuery(namedQuery, argsStruct)
+	return QueryGrouped[T](ctx, conn, query, args...)
+}
@@ -0,0 +1,72 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+type groupedPart struct {
+	Name string `db:"name"`
+}
+
+type groupedItem struct {
+	ID    int           `db:"id,pk"`
+	Qty   int           `db:"qty"`
+	Parts []groupedPart `db_children:"part_"`
+}
+
+type groupedOrder struct {
+	ID       int            `db:"id,pk"`
+	Customer string         `db:"customer"`
+	Items    []*groupedItem `db_children:"item_"`
+}
+
+func TestScanRowsGrouped(t *testing.T) {
+	cols := []fakeCol{
+		{"id", pgtype.Int8OID}, {"customer", pgtype.TextOID},
+		{"item_id", pgtype.Int8OID}, {"item_qty", pgtype.Int8OID}, {"item_part_name", pgtype.TextOID},
+	}
+	rows := newFakeRows(cols,
+		textRow("1", "alice", "10", "1", "bolt"),
+		textRow("1", "alice", "10", "1", "nut"),
+		textRow("1", "alice", "11", "2", "gear"),
+		[]*string{textRow("2")[0], textRow("bob")[0], nil, nil, nil},
+		[]*string{textRow("3")[0], textRow("carol")[0], textRow("10")[0], textRow("5")[0], nil},
+	)
+	var out []groupedOrder
+	err := ScanRowsGrouped(rows, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, []groupedOrder{
+		{ID: 1, Customer: "alice", Items: []*groupedItem{
+			{ID: 10, Qty: 1, Parts: []groupedPart{{"bolt"}, {"nut"}}},
+			{ID: 11, Qty: 2, Parts: []groupedPart{{"gear"}}},
+		}},
+		{ID: 2, Customer: "bob"},
+		// same item key under a different parent is a separate record
+		{ID: 3, Customer: "carol", Items: []*groupedItem{{ID: 10, Qty: 5}}},
+	}, out)
+}
+
+func TestScanRowsGroupedMissingKey(t *testing.T) {
+	cols := []fakeCol{{"id", pgtype.Int8OID}, {"item_qty", pgtype.Int8OID}}
+	var out []groupedOrder
+	err := ScanRowsGrouped(newFakeRows(cols), &out)
+	assert.EqualError(t, err, "key field item_id of struct groupedItem missing from result")
+}
+
+func TestScanRowsGroupedWithOptions(t *testing.T) {
+	cols := []fakeCol{{"id", pgtype.Int8OID}, {"item_id", pgtype.Int8OID}, {"item_qty", pgtype.Int8OID}}
+	var out []groupedOrder
+	err := ScanRowsGroupedWithOptions(newFakeRows(cols, textRow("1", "10", "1")), &out, ScanOptions{Strict: true})
+	var mismatch *ScanMismatchError
+	assert.ErrorAs(t, err, &mismatch)
+
+	err = ScanRowsGroupedWithOptions(newFakeRows(cols, textRow("1", "10", "1")), &out, ScanOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []groupedOrder{{ID: 1, Items: []*groupedItem{{ID: 10, Qty: 1}}}}, out)
+}
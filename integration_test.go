@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/bitcomplete/sqltestutil"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -26,20 +27,30 @@ CREATE TABLE accounts (
     user_id INT NOT NULL REFERENCES users (user_id),
     name VARCHAR NOT NULL,
     balance INT NOT NULL
+);
+
+CREATE TYPE account_summary AS (
+    name VARCHAR,
+    balance INT
 );`
 
 type User struct {
-	UserID int    `db:"user_id"`
+	UserID int    `db:"user_id,pk,generated"`
 	Name   string `db:"name"`
 }
 
 type Account struct {
-	AccountId int    `db:"account_id"`
+	AccountId int    `db:"account_id,pk,generated"`
 	UserId    int    `db:"user_id"`
 	Name      string `db:"name"`
 	Balance   int    `db:"balance"`
 }
 
+type AccountSummary struct {
+	Name    string `db:"name"`
+	Balance int    `db:"balance"`
+}
+
 func TestWithDatabase(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test")
@@ -53,19 +64,26 @@ func TestWithDatabase(t *testing.T) {
 	defer pg.Shutdown(context.Background())
 	require.NoError(t, err, "unable to create database")
 
-	pool, err := pgxpool.New(ctx, pg.ConnectionString())
+	// set a schema, before creating the pool as its connections load the composite types defined there
+	setupConn, err := pgx.Connect(ctx, pg.ConnectionString())
 	require.NoError(t, err, "error connecting to database")
-
-	// set a schema
-	_, err = pgxx.Exec(ctx, pool, TEST_SCHEMA)
+	_, err = pgxx.Exec(ctx, setupConn, TEST_SCHEMA)
 	require.NoError(t, err, "error creating schema")
+	setupConn.Close(ctx)
+
+	pgxx.DeclareCompositeType[AccountSummary]("account_summary")
+	config, err := pgxpool.ParseConfig(pg.ConnectionString())
+	require.NoError(t, err)
+	config.AfterConnect = pgxx.RegisterCompositeTypes
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	require.NoError(t, err, "error connecting to database")
 
 	// basic insertion snd scans
 	alice := User{Name: "Alice"}
 	bob := User{Name: "Bob"}
 
 	batch := pgxx.NewBatch()
-	insertUserQuery := pgxx.NamedInsertQuery("users", []pgxx.FieldName{"name"}) + " RETURNING user_id"
+	insertUserQuery := pgxx.NamedInsertQuery("users", pgxx.InsertableFields[User]()) + pgxx.Returning(pgxx.KeyFields[User]())
 	pgxx.QueueNamedQueryOne(batch, &alice.UserID, insertUserQuery, &alice)
 	pgxx.QueueNamedQueryOne(batch, &bob.UserID, insertUserQuery, &bob)
 	err = pgxx.RunBatch(ctx, pool, batch)
@@ -80,15 +98,62 @@ func TestWithDatabase(t *testing.T) {
 	assert.Equal(t, alice, users[0])
 	assert.Equal(t, bob, users[1])
 
+	// streaming results
+	var streamedUsers []User
+	for u, err := range pgxx.QueryIter[User](ctx, pool, selectUsersQuery) {
+		assert.NoError(t, err)
+		streamedUsers = append(streamedUsers, u)
+	}
+	assert.Equal(t, users, streamedUsers)
+
+	// dynamic queries using fragments
+	filteredUsers, err := pgxx.Query[User](ctx, pool, "SELECT "+pgxx.ListFields(pgxx.DBFields[User]())+" FROM users",
+		pgxx.Where(pgxx.And(pgxx.In("name", []string{"Bob", "Dave"}), pgxx.Optional("user_id > $1", (*int)(nil)))))
+	assert.NoError(t, err)
+	assert.Equal(t, []User{bob}, filteredUsers)
+
 	// CopyFrom
 	accounts := []Account{
 		{UserId: alice.UserID, Name: "chequing", Balance: 100},
 		{UserId: bob.UserID, Name: "chequing", Balance: 200},
 	}
-	nrows, err := pgxx.NamedCopyFrom(ctx, pool, "accounts", []pgxx.FieldName{"user_id", "name", "balance"}, accounts)
+	nrows, err := pgxx.NamedCopyFrom(ctx, pool, "accounts", pgxx.InsertableFields[Account](), accounts)
 	assert.NoError(t, err)
 	assert.Equal(t, len(accounts), nrows)
 
+	// keyed results
+	var accountsByUser map[int]Account
+	batch = pgxx.NewBatch()
+	pgxx.QueueQueryMap(batch, &accountsByUser, pgxx.KeyColumn[int, Account]("user_id"),
+		"SELECT "+pgxx.ListFields(pgxx.DBFields[Account]())+" FROM accounts")
+	err = pgxx.RunBatch(ctx, pool, batch)
+	assert.NoError(t, err)
+	assert.Len(t, accountsByUser, 2)
+	assert.Equal(t, 200, accountsByUser[bob.UserID].Balance)
+
+	// composite types, registered on each connection of the pool
+	type UserWithSummaries struct {
+		Name     string           `db:"name"`
+		Accounts []AccountSummary `db:"accounts"`
+	}
+	summaries, err := pgxx.QueryExactlyOne[UserWithSummaries](ctx, pool,
+		"SELECT u.name, array_agg(ROW(a.name, a.balance)::account_summary) AS accounts "+
+			"FROM users u JOIN accounts a ON u.user_id = a.user_id WHERE u.name = $1 GROUP BY u.name", "Bob")
+	assert.NoError(t, err)
+	assert.Equal(t, UserWithSummaries{Name: "Bob", Accounts: []AccountSummary{{Name: "chequing", Balance: 200}}}, summaries)
+
+	// prepared statements
+	conn, err := pool.Acquire(ctx)
+	require.NoError(t, err)
+	selectAccountsStmt, err := pgxx.PrepareNamed[Account](ctx, conn.Conn(), "select_accounts",
+		"SELECT "+pgxx.ListFields(pgxx.DBFields[Account]())+" FROM accounts WHERE user_id = @user_id")
+	require.NoError(t, err)
+	bobAccounts, err := pgxx.QueryStmt[Account](ctx, conn, selectAccountsStmt, Account{UserId: bob.UserID})
+	assert.NoError(t, err)
+	assert.Len(t, bobAccounts, 1)
+	assert.Equal(t, 200, bobAccounts[0].Balance)
+	conn.Release()
+
 	// single selects
 	selectAccountQuery := "SELECT " + pgxx.ListFields(pgxx.DBFields[Account]()) + " FROM accounts WHERE user_id = $1 and name = $2"
 	// can return either the struct itself or a pointer
@@ -120,6 +185,45 @@ func TestWithDatabase(t *testing.T) {
 		Account: *accountA,
 	}
 	assert.Equal(t, expectedJoinedResult, joinedResult)
+	// the same join as a tuple without a wrapper struct
+	joinedPairs, err := pgxx.Query2[User, Account](ctx, pool, pgxx.SplitByPrefix("u_", "a_"), queryWithJoin, "Alice")
+	assert.NoError(t, err)
+	assert.Equal(t, []pgxx.Tuple2[User, Account]{{A: alice, B: *accountA}}, joinedPairs)
+
+	// optional relations using a LEFT JOIN and a pointer prefix
+	type UserWithAccount struct {
+		User    User     `db_prefix:"u_"`
+		Account *Account `db_prefix:"a_"`
+	}
+	carol := User{Name: "Carol"}
+	carol.UserID, err = pgxx.NamedQueryExactlyOne[int](ctx, pool, insertUserQuery, &carol)
+	assert.NoError(t, err)
+	queryWithLeftJoin := "SELECT " +
+		pgxx.ListFieldsWithPrefix(pgxx.DBFields[User](), "u.", "u_") + ", " +
+		pgxx.ListFieldsWithPrefix(pgxx.DBFields[Account](), "a.", "a_") +
+		" FROM users u LEFT JOIN accounts a ON u.user_id = a.user_id " +
+		"WHERE u.name = $1"
+	carolResult, err := pgxx.QueryExactlyOne[UserWithAccount](ctx, pool, queryWithLeftJoin, "Carol")
+	assert.NoError(t, err)
+	assert.Equal(t, UserWithAccount{User: carol}, carolResult)
+	aliceResult, err := pgxx.QueryExactlyOne[UserWithAccount](ctx, pool, queryWithLeftJoin, "Alice")
+	assert.NoError(t, err)
+	assert.Equal(t, UserWithAccount{User: alice, Account: accountA}, aliceResult)
+
+	// keyset pagination
+	selectAllUsersQuery := "SELECT " + pgxx.ListFields(pgxx.DBFields[User]()) + " FROM users"
+	userSort := []pgxx.SortKey{pgxx.Asc("name"), pgxx.Asc("user_id")}
+	page1, err := pgxx.Paginate[User](ctx, pool, selectAllUsersQuery, userSort, 2, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []User{alice, bob}, page1.Items)
+	assert.Empty(t, page1.Prev)
+	page2, err := pgxx.Paginate[User](ctx, pool, selectAllUsersQuery, userSort, 2, page1.Next)
+	assert.NoError(t, err)
+	assert.Equal(t, []User{carol}, page2.Items)
+	assert.Empty(t, page2.Next)
+	page1Again, err := pgxx.Paginate[User](ctx, pool, selectAllUsersQuery, userSort, 2, page2.Prev)
+	assert.NoError(t, err)
+	assert.Equal(t, page1.Items, page1Again.Items)
 
 	// test colliding transactions
 	tx1Retries := 0
@@ -0,0 +1,75 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Encoder for struct fields tagged with the json option (such as `db:"payload,json"`),
+// which are marshalled when used as parameters and unmarshalled when scanned.
+type JSONCodec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+type stdJSONCodec struct{}
+
+func (stdJSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (stdJSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// Codec used for fields tagged with the json option, using encoding/json by default. Changeable.
+var DefaultJSONCodec JSONCodec = stdJSONCodec{}
+
+// Encodes a field tagged with the json option as a parameter.
+// Nil pointers, maps, slices, and interfaces are passed as NULL rather than a JSON null.
+func jsonArg(fval reflect.Value) (any, error) {
+	switch fval.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		if fval.IsNil() {
+			return nil, nil
+		}
+	}
+	data, err := DefaultJSONCodec.Marshal(fval.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan target holding the raw text of a column tagged with the json option until it is decoded.
+type jsonScanTarget struct {
+	data  []byte
+	valid bool
+}
+
+func (j *jsonScanTarget) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		j.data, j.valid = j.data[:0], false
+	case string:
+		j.data, j.valid = append(j.data[:0], src...), true
+	case []byte:
+		j.data, j.valid = append(j.data[:0], src...), true
+	default:
+		return fmt.Errorf("cannot scan %T as JSON", src)
+	}
+	return nil
+}
+
+// Decodes a scanned column into a field, setting it to the zero value for NULL.
+func (j *jsonScanTarget) decodeInto(fval reflect.Value) error {
+	fval.SetZero()
+	if !j.valid {
+		return nil
+	}
+	return DefaultJSONCodec.Unmarshal(j.data, fval.Addr().Interface())
+}
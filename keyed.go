@@ -0,0 +1,141 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Returns a function extracting the value of a db-tagged field from a struct (or pointer to struct),
+// for use as the key function of [QueryMap] and [QueryGroupBy].
+// Panics if V does not have the field or if it is not of type K.
+// Fields inside a nil embedded pointer produce the zero key.
+func KeyColumn[K comparable, V any](field FieldName) func(V) K {
+	mapping := structMappingFor[V]()
+	idx, found := mapping.FieldMappings[field]
+	if !found {
+		panic(fmt.Errorf("missing database field %s in struct %s", field, mapping.StructType.Name()))
+	}
+	if ft := fieldTypeByPath(mapping.StructType, idx); ft != reflect.TypeFor[K]() {
+		panic(fmt.Errorf("database field %s in struct %s has type %v, not %v", field, mapping.StructType.Name(), ft, reflect.TypeFor[K]()))
+	}
+	return func(v V) K {
+		val := reflect.Indirect(reflect.ValueOf(&v).Elem())
+		if !val.IsValid() {
+			var zero K
+			return zero
+		}
+		fval, err := val.FieldByIndexErr(idx)
+		if err != nil {
+			var zero K
+			return zero
+		}
+		return fval.Interface().(K)
+	}
+}
+
+// Scan rows into a map keyed by keyOf. If multiple rows have the same key, the last one is kept.
+func ScanRowsMap[K comparable, V any](rows pgx.Rows, dst *map[K]V, keyOf func(V) K) error {
+	out := make(map[K]V)
+	for record, err := range IterRows[V](rows) {
+		if err != nil {
+			return err
+		}
+		out[keyOf(record)] = record
+	}
+	*dst = out
+	return nil
+}
+
+// Scan rows into a map of slices keyed by keyOf, with rows in each slice kept in order.
+func ScanRowsGroupBy[K comparable, V any](rows pgx.Rows, dst *map[K][]V, keyOf func(V) K) error {
+	out := make(map[K][]V)
+	for record, err := range IterRows[V](rows) {
+		if err != nil {
+			return err
+		}
+		k := keyOf(record)
+		out[k] = append(out[k], record)
+	}
+	*dst = out
+	return nil
+}
+
+// Run a query with positional parameters and read out the results as a map keyed by keyOf
+// (which may be made from a column using [KeyColumn]). If multiple rows have the same key, the last one is kept.
+func QueryMap[K comparable, V any](ctx context.Context, conn PoolOrTx, keyOf func(V) K, query SQL, args ...any) (map[K]V, error) {
+	cursor, err := conn.Query(ctx, string(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	var out map[K]V
+	err = ScanRowsMap(cursor, &out, keyOf)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Run a query with named parameters (pulling them out of a struct) and read out the results as a map keyed by keyOf
+// (which may be made from a column using [KeyColumn]). If multiple rows have the same key, the last one is kept.
+func NamedQueryMap[K comparable, V any](ctx context.Context, conn PoolOrTx, keyOf func(V) K, namedQuery SQL, argsStruct any) (map[K]V, error) {
+	query, args := ExtractNamedQuery(namedQuery, argsStruct)
+	return QueryMap(ctx, conn, keyOf, query, args...)
+}
+
+// Run a query with positional parameters and read out the results grouped into slices keyed by keyOf
+// (which may be made from a column using [KeyColumn]).
+func QueryGroupBy[K comparable, V any](ctx context.Context, conn PoolOrTx, keyOf func(V) K, query SQL, args ...any) (map[K][]V, error) {
+	cursor, err := conn.Query(ctx, string(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	var out map[K][]V
+	err = ScanRowsGroupBy(cursor, &out, keyOf)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Run a query with named parameters (pulling them out of a struct) and read out the results
+// grouped into slices keyed by keyOf (which may be made from a column using [KeyColumn]).
+func NamedQueryGroupBy[K comparable, V any](ctx context.Context, conn PoolOrTx, keyOf func(V) K, namedQuery SQL, argsStruct any) (map[K][]V, error) {
+	query, args := ExtractNamedQuery(namedQuery, argsStruct)
+	return QueryGroupBy(ctx, conn, keyOf, query, args...)
+}
+
+// Version of QueryMap which queues to a batch.
+// Writes results into *out (which must not be nil) when the batch is run.
+func QueueQueryMap[K comparable, V any](batch *pgx.Batch, out *map[K]V, keyOf func(V) K, query SQL, args ...any) {
+	batch.Queue(string(query), args...).Query(func(cursor pgx.Rows) error {
+		return ScanRowsMap(cursor, out, keyOf)
+	})
+}
+
+// Version of NamedQueryMap which queues to a batch.
+// Writes results into *out (which must not be nil) when the batch is run.
+func QueueNamedQueryMap[K comparable, V any](batch *pgx.Batch, out *map[K]V, keyOf func(V) K, namedQuery SQL, argsStruct any) {
+	query, args := ExtractNamedQuery(namedQuery, argsStruct)
+	QueueQueryMap(batch, out, keyOf, query, args...)
+}
+
+// Version of QueryGroupBy which queues to a batch.
+// Writes results into *out (which must not be nil) when the batch is run.
+func QueueQueryGroupBy[K comparable, V any](batch *pgx.Batch, out *map[K][]V, keyOf func(V) K, query SQL, args ...any) {
+	batch.Queue(string(query), args...).Query(func(cursor pgx.Rows) error {
+		return ScanRowsGroupBy(cursor, out, keyOf)
+	})
+}
+
+// Version of NamedQueryGroupBy which queues to a batch.
+// Writes results into *out (which must not be nil) when the batch is run.
+func QueueNamedQueryGroupBy[K comparable, V any](batch *pgx.Batch, out *map[K][]V, keyOf func(V) K, namedQuery SQL, argsStruct any) {
+	query, args := ExtractNamedQuery(namedQuery, argsStruct)
+	QueueQueryGroupBy(batch, out, keyOf, query, args...)
+}
@@ -0,0 +1,106 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Bounded cache evicting the least recently used entries, safe for concurrent use.
+// Lookups only take a read lock, so recency is approximate: entries are stamped with a clock
+// which only advances when an entry is added, and eviction searches for the oldest stamp.
+type lruCache[K comparable, V any] struct {
+	lock                    sync.RWMutex
+	entries                 map[K]*lruEntry[V]
+	size                    int
+	clock                   atomic.Uint64
+	hits, misses, evictions atomic.Uint64
+}
+
+type lruEntry[V any] struct {
+	value V
+	used  atomic.Uint64 // clock at the last lookup or when added
+}
+
+func newLRUCache[K comparable, V any](size int) *lruCache[K, V] {
+	return &lruCache[K, V]{entries: make(map[K]*lruEntry[V]), size: size}
+}
+
+// Looks up an entry, counting a hit or miss.
+func (c *lruCache[K, V]) get(key K) (V, bool) {
+	c.lock.RLock()
+	e, ok := c.entries[key]
+	c.lock.RUnlock()
+	if !ok {
+		c.misses.Add(1)
+		var zero V
+		return zero, false
+	}
+	c.hits.Add(1)
+	// only write when the clock has moved to avoid contention on hot entries
+	if now := c.clock.Load(); e.used.Load() != now {
+		e.used.Store(now)
+	}
+	return e.value, true
+}
+
+// Adds an entry if it is not already present, evicting others as needed.
+func (c *lruCache[K, V]) put(key K, value V) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.entries[key]; ok || c.size <= 0 {
+		return
+	}
+	c.evict(c.size - 1)
+	// stamped before advancing the clock, so entries looked up since are considered more recent
+	e := &lruEntry[V]{value: value}
+	e.used.Store(c.clock.Add(1) - 1)
+	c.entries[key] = e
+}
+
+// Removes the least recently used entries until at most n remain. Must be called with the lock held.
+func (c *lruCache[K, V]) evict(n int) {
+	for len(c.entries) > n {
+		var oldest K
+		oldestUsed := ^uint64(0)
+		for k, e := range c.entries {
+			if used := e.used.Load(); used < oldestUsed {
+				oldest, oldestUsed = k, used
+			}
+		}
+		delete(c.entries, oldest)
+		c.evictions.Add(1)
+	}
+}
+
+func (c *lruCache[K, V]) setSize(size int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.size = max(size, 0)
+	c.evict(c.size)
+}
+
+func (c *lruCache[K, V]) len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return len(c.entries)
+}
+
+// Removes all entries, keeping the statistics.
+func (c *lruCache[K, V]) clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	clear(c.entries)
+}
+
+// Removes all entries and resets the statistics.
+func (c *lruCache[K, V]) reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	clear(c.entries)
+	c.hits.Store(0)
+	c.misses.Store(0)
+	c.evictions.Store(0)
+}
@@ -8,6 +8,8 @@ import (
 	"slices"
 	"testing"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -78,6 +80,74 @@ func TestNamedQuery(t *testing.T) {
 	assert.Equal(t, expectedArgs, args)
 }
 
+func TestNamedQueryFromMaps(t *testing.T) {
+	const namedQuery SQL = `UPDATE foo SET b = @b, c = @c WHERE a = @a`
+	query, args := ExtractNamedQuery(namedQuery, pgx.NamedArgs{"a": 1, "b": "a", "c": 1.0})
+	assert.Equal(t, SQL(`UPDATE foo SET b = $1, c = $2 WHERE a = $3`), query)
+	assert.Equal(t, []any{"a", 1.0, 1}, args)
+
+	// later sources take precedence
+	foo := Foo{A: 1, B: "a", Bar: Bar{C: 1.0}}
+	_, args = ExtractNamedQuery(namedQuery, MergeArgs(&foo, map[string]any{"b": "override"}))
+	assert.Equal(t, []any{"override", 1.0, 1}, args)
+
+	_, _, err := MaybeExtractNamedQuery(namedQuery, map[string]any{"a": 1})
+	assert.EqualError(t, err, "missing database field b in map")
+	_, _, err = MaybeExtractNamedQuery("SELECT @d", MergeArgs(foo, pgx.NamedArgs{}))
+	assert.EqualError(t, err, "missing database field d in struct Foo or map")
+}
+
+func TestNamedQuerySliceExpansion(t *testing.T) {
+	const namedQuery SQL = `SELECT * FROM foo WHERE a IN (@ids...) AND b = @b AND NOT (a = ANY(@ids))`
+	query, args := ExtractNamedQuery(namedQuery, map[string]any{"ids": []int{4, 5, 6}, "b": "x"})
+	assert.Equal(t, SQL(`SELECT * FROM foo WHERE a IN ($2, $3, $4) AND b = $5 AND NOT (a = ANY($1))`), query)
+	assert.Equal(t, []any{[]int{4, 5, 6}, 4, 5, 6, "x"}, args)
+
+	query, args = ExtractNamedQuery(`SELECT * FROM foo WHERE a NOT IN (@ids...)`, map[string]any{"ids": []int{4}})
+	assert.Equal(t, SQL(`SELECT * FROM foo WHERE a NOT IN ($1)`), query)
+	assert.Equal(t, []any{4}, args)
+
+	// an empty list would make NOT IN (NULL) exclude every row, so is rejected
+	_, _, err := MaybeExtractNamedQuery(`SELECT * FROM foo WHERE a NOT IN (@ids...)`, map[string]any{"ids": []int{}})
+	assert.EqualError(t, err, "expanded parameter ids is empty (compare using = ANY(@ids) to allow empty slices)")
+	_, _, err = MaybeExtractNamedQuery(`SELECT * FROM foo WHERE a IN (@ids...)`, map[string]any{"ids": nil})
+	assert.EqualError(t, err, "expanded parameter ids is empty (compare using = ANY(@ids) to allow empty slices)")
+
+	_, _, err = MaybeExtractNamedQuery(`SELECT @a...`, Foo{})
+	assert.EqualError(t, err, "expanded parameter a must be a slice, got int")
+}
+
+func TestNamedQueryDottedPaths(t *testing.T) {
+	type Nested struct {
+		Foo  Foo2   `db_prefix:"foo_"`
+		Bar  *Bar   `db_prefix:"bar"`
+		Name string `db:"name"`
+	}
+	val := Nested{Foo: Foo2{A: 1, Bar2: &Bar{C: 1.5}}, Name: "n"}
+	query, args := ExtractNamedQuery("SELECT @foo.a, @foo.bar.c, @foo_bar_c, @bar.c, @name", val)
+	assert.Equal(t, SQL("SELECT $1, $2, $3, $4, $5"), query)
+	// fields inside nil pointers are NULL
+	assert.Equal(t, []any{1, 1.5, 1.5, nil, "n"}, args)
+
+	_, _, err := MaybeExtractNamedQuery("SELECT @foo.c", val)
+	assert.EqualError(t, err, "missing database field foo.c in struct Nested")
+}
+
+func TestNamedQueryWithArgs(t *testing.T) {
+	foo := Foo{A: 1, B: "a"}
+	query, args := ExtractNamedQueryWithArgs("SELECT * FROM foo WHERE b = @b AND a > $2 AND '$5' <> @b LIMIT $1", foo, 10, 0)
+	assert.Equal(t, SQL("SELECT * FROM foo WHERE b = $3 AND a > $2 AND '$5' <> $3 LIMIT $1"), query)
+	assert.Equal(t, []any{10, 0, "a"}, args)
+
+	query, args = ExtractNamedQueryWithArgs("SELECT * FROM foo WHERE a IN (@ids...) LIMIT $1", map[string]any{"ids": []int{4, 5}}, 10)
+	assert.Equal(t, SQL("SELECT * FROM foo WHERE a IN ($2, $3) LIMIT $1"), query)
+	assert.Equal(t, []any{10, 4, 5}, args)
+
+	assert.PanicsWithError(t, "query uses 1 positional parameters, but 0 were given", func() {
+		ExtractNamedQueryWithArgs("SELECT @a LIMIT $1", foo)
+	})
+}
+
 func TestExtractScanPointers(t *testing.T) {
 	var foo Foo2
 	fooMapping := structMappingFor[Foo]()
@@ -89,3 +159,93 @@ func TestExtractScanPointers(t *testing.T) {
 	assert.Same(t, &foo.B, ptrs[1])
 	assert.Same(t, &foo.Bar2.C, ptrs[2])
 }
+
+type jsonPayload struct {
+	Tags  []string `json:"tags"`
+	Count int      `json:"count"`
+}
+
+type withJSON struct {
+	ID      int            `db:"id"`
+	Payload jsonPayload    `db:"payload,json"`
+	Extra   map[string]int `db:"extra,json"`
+}
+
+func TestJSONFields(t *testing.T) {
+	_, args := ExtractNamedQuery("INSERT INTO t VALUES (@id, @payload, @extra)", withJSON{ID: 1, Payload: jsonPayload{Tags: []string{"a"}, Count: 2}})
+	assert.Equal(t, []any{1, `{"tags":["a"],"count":2}`, nil}, args)
+
+	cols := []fakeCol{{"id", pgtype.Int8OID}, {"payload", pgtype.JSONBOID}, {"extra", pgtype.JSONOID}}
+	var out []withJSON
+	err := ScanRows(newFakeRows(cols, textRow("1", `{"tags": ["a", "b"], "count": 3}`, `{"x": 1}`), []*string{textRow("2")[0], textRow("{}")[0], nil}), &out)
+	assert.NoError(t, err)
+	assert.Equal(t, []withJSON{
+		{ID: 1, Payload: jsonPayload{Tags: []string{"a", "b"}, Count: 3}, Extra: map[string]int{"x": 1}},
+		{ID: 2},
+	}, out)
+}
+
+type withTagOptions struct {
+	ID        int    `db:"id,pk,generated"`
+	Tenant    string `db:"tenant,pk"`
+	Name      string `db:"name"`
+	CreatedBy string `db:"created_by,insertonly"`
+	CreatedAt string `db:"created_at,default,insertonly"`
+	Total     int    `db:"total,readonly"`
+}
+
+type withUnknownTagOption struct {
+	ID int `db:"id,primary"`
+}
+
+func TestTagOptions(t *testing.T) {
+	assert.Equal(t, []FieldName{"id", "tenant", "name", "created_by", "created_at", "total"}, DBFields[withTagOptions]())
+	assert.Equal(t, []FieldName{"id", "tenant"}, KeyFields[withTagOptions]())
+	assert.Equal(t, []FieldName{"tenant", "name", "created_by"}, InsertableFields[withTagOptions]())
+	assert.Equal(t, []FieldName{"name"}, UpdatableFields[withTagOptions]())
+	assert.Equal(t, []FieldName{"id", "created_at", "total"}, FieldsWithOption[withTagOptions]("generated", "readonly", "default"))
+
+	assert.PanicsWithError(t, "unknown option primary in db tag of field id", func() { DBFields[withUnknownTagOption]() })
+}
+
+type SnakeCaseEmbedded struct {
+	CreatedAt string
+}
+
+type snakeCaseRecord struct {
+	SnakeCaseColumns
+	UserID     int
+	HTTPStatus int
+	Name       string `db:"full_name"`
+	Skipped    string `db:"-"`
+	Bar        Bar    `db_prefix:"bar_"`
+	SnakeCaseEmbedded
+	internal int
+}
+
+type customNamedRecord struct {
+	UserID int
+	Name   string
+}
+
+func (customNamedRecord) DBColumnName(fieldName string) string {
+	return "x_" + LowerCase(fieldName)
+}
+
+func TestNamingPolicy(t *testing.T) {
+	assert.Equal(t, "user_id", SnakeCase("UserID"))
+	assert.Equal(t, "http_server", SnakeCase("HTTPServer"))
+	assert.Equal(t, "account_id", SnakeCase("AccountId"))
+	assert.Equal(t, "field2_name", SnakeCase("Field2Name"))
+
+	assert.Equal(t, []FieldName{"user_id", "http_status", "full_name", "bar_c", "created_at"}, DBFields[snakeCaseRecord]())
+	assert.Equal(t, []FieldName{"x_userid", "x_name"}, DBFields[customNamedRecord]())
+	// untagged fields are ignored without a policy
+	assert.Empty(t, DBFields[SnakeCaseEmbedded]())
+
+	// changing the default policy replaces cached mappings
+	SetDefaultNamingPolicy(LowerCase)
+	assert.Equal(t, []FieldName{"createdat"}, DBFields[SnakeCaseEmbedded]())
+	SetDefaultNamingPolicy(nil)
+	assert.Empty(t, DBFields[SnakeCaseEmbedded]())
+}
@@ -0,0 +1,106 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Several sources of named parameters combined into one, for use wherever a struct of named parameters is accepted.
+// Each parameter is taken from the last source which has it, so later sources override earlier ones.
+type MergedArgs []any
+
+// Combines sources of named parameters (structs, pointers to structs, map[string]any, or pgx.NamedArgs),
+// such as a struct followed by a map of overrides. Parameters are taken from the last source which has them.
+// Nested MergedArgs are flattened.
+func MergeArgs(sources ...any) MergedArgs {
+	var out MergedArgs
+	for _, src := range sources {
+		if m, ok := src.(MergedArgs); ok {
+			out = append(out, m...)
+		} else {
+			out = append(out, src)
+		}
+	}
+	return out
+}
+
+// A source of named parameters: either a struct (with its mapping) or a map.
+type namedArgSource struct {
+	mapping *structMapping
+	val     reflect.Value
+	m       map[string]any
+}
+
+// Determines how to read named parameters from a struct, pointer to struct, map[string]any, or pgx.NamedArgs.
+// Panics on other types.
+func namedArgSourceOf(src any) namedArgSource {
+	switch src := src.(type) {
+	case map[string]any:
+		return namedArgSource{m: src}
+	case pgx.NamedArgs:
+		return namedArgSource{m: src}
+	}
+	val := reflect.Indirect(reflect.ValueOf(src))
+	mapping := structMappingOf(val.Type())
+	return namedArgSource{mapping: &mapping, val: val}
+}
+
+func (s namedArgSource) name() string {
+	if s.mapping != nil {
+		return "struct " + s.mapping.StructType.Name()
+	}
+	return "map"
+}
+
+// Looks up the value of a named parameter, reporting whether the source has it.
+func (s namedArgSource) lookup(f FieldName) (any, bool, error) {
+	if s.mapping != nil {
+		return s.mapping.namedArg(f, s.val)
+	}
+	v, found := s.m[string(f)]
+	return v, found, nil
+}
+
+// Extracts the values of named parameters (in order) from a struct, map, or MergedArgs.
+// Panics if the source is not one of these types, and errors if any parameter is missing.
+func extractNamedArgs(fields []FieldName, src any) ([]any, error) {
+	merged, isMerged := src.(MergedArgs)
+	if !isMerged {
+		source := namedArgSourceOf(src)
+		if source.mapping != nil {
+			// common case of a single struct
+			return source.mapping.extractNamedArgs(fields, source.val)
+		}
+		merged = MergedArgs{src}
+	}
+
+	sources := make([]namedArgSource, len(merged))
+	for i, src := range merged {
+		sources[i] = namedArgSourceOf(src)
+	}
+	args := make([]any, len(fields))
+	for i, f := range fields {
+		found := false
+		for j := len(sources) - 1; j >= 0 && !found; j-- {
+			var err error
+			args[i], found, err = sources[j].lookup(f)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !found {
+			names := make([]string, len(sources))
+			for j, s := range sources {
+				names[j] = s.name()
+			}
+			return nil, fmt.Errorf("missing database field %s in %s", f, strings.Join(names, " or "))
+		}
+	}
+	return args, nil
+}
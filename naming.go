@@ -0,0 +1,87 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Function deriving a column name from the name of an untagged struct field.
+type NamingPolicy func(fieldName string) string
+
+// Naming policy converting field names to snake_case (such as UserID to user_id).
+func SnakeCase(fieldName string) string {
+	runes := []rune(fieldName)
+	var sb strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+				sb.WriteByte('_')
+			}
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
+
+// Naming policy converting field names to lower case (such as UserID to userid).
+func LowerCase(fieldName string) string {
+	return strings.ToLower(fieldName)
+}
+
+// Naming policy used for structs without their own, guarded by structMappingsLock.
+var defaultNamingPolicy NamingPolicy
+
+// Sets the naming policy used to map untagged exported fields of structs without their own naming marker.
+// Nil by default, in which case untagged fields are ignored.
+// Clears the cached struct mappings and scan plans so that the policy applies to all later queries,
+// though queries running at the same time (and composite types already registered on connections)
+// may still use the previous one.
+func SetDefaultNamingPolicy(policy NamingPolicy) {
+	structMappingsLock.Lock()
+	defaultNamingPolicy = policy
+	clear(structMappingsCache)
+	structMappingsLock.Unlock()
+	scanPlans.clear()
+}
+
+// Marker which can be embedded in a struct to map its untagged exported fields (and those of its embedded structs)
+// to snake_case column names.
+// Explicit db tags take precedence, and fields tagged with `db:"-"` are skipped.
+type SnakeCaseColumns struct{}
+
+// Marker which can be embedded in a struct to map its untagged exported fields (and those of its embedded structs)
+// to lower case column names.
+// Explicit db tags take precedence, and fields tagged with `db:"-"` are skipped.
+type LowerCaseColumns struct{}
+
+// Interface for structs providing their own naming policy for untagged exported fields
+// (and those of their embedded structs), which takes precedence over marker fields.
+type ColumnNamer interface {
+	DBColumnName(fieldName string) string
+}
+
+// Returns the naming policy declared by a struct type itself, or nil if there is none.
+func namingPolicyOf(t reflect.Type) NamingPolicy {
+	if reflect.PointerTo(t).Implements(reflect.TypeFor[ColumnNamer]()) {
+		return reflect.New(t).Interface().(ColumnNamer).DBColumnName
+	}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.Anonymous {
+			continue
+		}
+		switch f.Type {
+		case reflect.TypeFor[SnakeCaseColumns]():
+			return SnakeCase
+		case reflect.TypeFor[LowerCaseColumns]():
+			return LowerCase
+		}
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// A column to sort by when paginating, along with its direction.
+type SortKey struct {
+	Field      FieldName
+	Descending bool
+}
+
+// Sort by a field in ascending order.
+func Asc(field FieldName) SortKey {
+	return SortKey{Field: field}
+}
+
+// Sort by a field in descending order.
+func Desc(field FieldName) SortKey {
+	return SortKey{Field: field, Descending: true}
+}
+
+// A page of results from Paginate, along with opaque tokens for fetching the adjacent pages.
+// Tokens are empty if there is no adjacent page in that direction.
+type Page[T any] struct {
+	Items []T
+	Next  string
+	Prev  string
+}
+
+// Returned when a pagination token cannot be decoded or was created for a different sort order.
+var ErrInvalidPageToken = errors.New("invalid pagination token")
+
+// Serialized contents of a pagination token: the direction and sort key values of the row to continue from.
+type pageToken struct {
+	Backward bool              `json:"b,omitempty"`
+	Fields   []FieldName       `json:"f"`
+	Values   []json.RawMessage `json:"v"`
+}
+
+func encodePageToken[T any](mapping structMapping, sort []SortKey, row *T, backward bool) string {
+	val := reflect.Indirect(reflect.ValueOf(row).Elem())
+	tok := pageToken{Backward: backward}
+	for _, k := range sort {
+		tok.Fields = append(tok.Fields, k.Field)
+		var fv any
+		if val.IsValid() {
+			f, err := val.FieldByIndexErr(mapping.FieldMappings[k.Field])
+			if err == nil {
+				fv = f.Interface()
+			}
+		}
+		v, err := json.Marshal(fv)
+		if err != nil {
+			panic(fmt.Errorf("cannot encode sort field %s of struct %s into pagination token: %w", k.Field, mapping.StructType.Name(), err))
+		}
+		tok.Values = append(tok.Values, v)
+	}
+	data, _ := json.Marshal(tok)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decodes a token into the direction and sort key values (as the types of their fields) to continue from.
+func decodePageToken(mapping structMapping, sort []SortKey, token string) (bool, []any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return false, nil, ErrInvalidPageToken
+	}
+	var tok pageToken
+	err = json.Unmarshal(data, &tok)
+	if err != nil || len(tok.Fields) != len(sort) || len(tok.Values) != len(sort) {
+		return false, nil, ErrInvalidPageToken
+	}
+	values := make([]any, len(sort))
+	for i, k := range sort {
+		if tok.Fields[i] != k.Field {
+			return false, nil, ErrInvalidPageToken
+		}
+		v := reflect.New(fieldTypeByPath(mapping.StructType, mapping.FieldMappings[k.Field]))
+		err = json.Unmarshal(tok.Values[i], v.Interface())
+		if err != nil {
+			return false, nil, ErrInvalidPageToken
+		}
+		values[i] = v.Elem().Interface()
+	}
+	return tok.Backward, values, nil
+}
+
+// Builds a query fetching up to limit+1 rows of a page after (or before, if backward) the row with the given sort key values.
+// Placeholders for the key values are numbered after the nargs parameters already used by the base query.
+func paginationQuery(query SQL, sort []SortKey, limit int, backward bool, after []any, nargs int) (SQL, []any) {
+	out := "SELECT * FROM (" + query + ") AS page"
+	var args []any
+	if after != nil {
+		params := make([]SQL, len(sort))
+		for i := range sort {
+			params[i] = "$" + SQL(strconv.Itoa(nargs+i+1))
+		}
+		args = after
+
+		sameDirection := true
+		for _, k := range sort[1:] {
+			if k.Descending != sort[0].Descending {
+				sameDirection = false
+			}
+		}
+		if sameDirection {
+			// a row comparison can use a multi-column index directly
+			cols := make([]FieldName, len(sort))
+			for i, k := range sort {
+				cols[i] = k.Field
+			}
+			op := SQL(" > ")
+			if sort[0].Descending != backward {
+				op = " < "
+			}
+			out += " WHERE (" + ListFields(cols) + ")" + op + "(" + params[0]
+			for _, p := range params[1:] {
+				out += ", " + p
+			}
+			out += ")"
+		} else {
+			// expand to (a > $1) OR (a = $1 AND b < $2) OR ...
+			out += " WHERE "
+			for i, k := range sort {
+				if i > 0 {
+					out += " OR "
+				}
+				out += "("
+				for j := range i {
+					out += SQL(sort[j].Field) + " = " + params[j] + " AND "
+				}
+				op := SQL(" > ")
+				if k.Descending != backward {
+					op = " < "
+				}
+				out += SQL(k.Field) + op + params[i] + ")"
+			}
+		}
+	}
+
+	out += " ORDER BY "
+	for i, k := range sort {
+		if i > 0 {
+			out += ", "
+		}
+		out += SQL(k.Field)
+		if k.Descending != backward {
+			out += " DESC"
+		}
+	}
+	out += " LIMIT " + SQL(strconv.Itoa(limit+1))
+	return out, args
+}
+
+// Reports whether pgx treats a query argument as an option or rewriter rather than a parameter value.
+func isQueryOption(arg any) bool {
+	switch arg.(type) {
+	case pgx.QueryExecMode, pgx.QueryResultFormats, pgx.QueryResultFormatsByOID, pgx.QueryRewriter:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run a query with positional parameters and read out one page of its results,
+// ordered by the given sort keys (which must be db fields of T that uniquely identify a row and are not NULL).
+// The query must not have its own ORDER BY or LIMIT, as it is wrapped in a subquery which adds them.
+// An empty token fetches the first page, and the Next and Prev tokens of the result fetch the adjacent pages
+// (by encoding the sort key values of the last or first row).
+// Args must only hold the values of positional parameters, as the query they are sent with is the wrapping one.
+// Panics if the sort keys are not fields of T, if limit is not positive,
+// or if args include pgx options or query rewriters (such as a Fragment or pgx.NamedArgs).
+func Paginate[T any](ctx context.Context, conn PoolOrTx, query SQL, sort []SortKey, limit int, token string, args ...any) (Page[T], error) {
+	mapping := structMappingFor[T]()
+	if len(sort) == 0 || limit <= 0 {
+		panic(errors.New("pagination requires at least one sort key and a positive limit"))
+	}
+	for _, arg := range args {
+		if isQueryOption(arg) {
+			panic(fmt.Errorf("pagination arguments must be positional parameters, got %T", arg))
+		}
+	}
+	for _, k := range sort {
+		if _, found := mapping.FieldMappings[k.Field]; !found {
+			panic(fmt.Errorf("missing database field %s in struct %s", k.Field, mapping.StructType.Name()))
+		}
+	}
+
+	var backward bool
+	var after []any
+	if token != "" {
+		var err error
+		backward, after, err = decodePageToken(mapping, sort, token)
+		if err != nil {
+			return Page[T]{}, err
+		}
+	}
+
+	pageQuery, extraArgs := paginationQuery(query, sort, limit, backward, after, len(args))
+	allArgs := append(append([]any{}, args...), extraArgs...)
+	items, err := Query[T](ctx, conn, pageQuery, allArgs...)
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	more := len(items) > limit
+	if more {
+		items = items[:limit]
+	}
+	if backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	page := Page[T]{Items: items}
+	if len(items) == 0 {
+		return page, nil
+	}
+	// when paging in one direction, there is always a page to return to in the other
+	if more || backward {
+		page.Next = encodePageToken(mapping, sort, &items[len(items)-1], false)
+	}
+	if (backward && more) || (!backward && token != "") {
+		page.Prev = encodePageToken(mapping, sort, &items[0], true)
+	}
+	return page, nil
+}
+
+// Version of Paginate with named parameters (pulling them out of a struct).
+func NamedPaginate[T any](ctx context.Context, conn PoolOrTx, namedQuery SQL, argsStruct any, sort []SortKey, limit int, token string) (Page[T], error) {
+	query, args := ExtractNamedQuery(namedQuery, argsStruct)
+	return Paginate[T](ctx, conn, query, sort, limit, token, args...)
+}
@@ -0,0 +1,60 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+type Event struct {
+	ID        int       `db:"id"`
+	CreatedAt time.Time `db:"created_at"`
+	Name      string    `db:"name"`
+}
+
+func TestPaginationQuery(t *testing.T) {
+	q, args := paginationQuery("SELECT * FROM events WHERE owner = $1", []SortKey{Desc("created_at"), Desc("id")}, 10, false, nil, 1)
+	assert.Equal(t, SQL("SELECT * FROM (SELECT * FROM events WHERE owner = $1) AS page ORDER BY created_at DESC, id DESC LIMIT 11"), q)
+	assert.Empty(t, args)
+
+	q, args = paginationQuery("SELECT * FROM events WHERE owner = $1", []SortKey{Desc("created_at"), Desc("id")}, 10, false, []any{"t", 5}, 1)
+	assert.Equal(t, SQL("SELECT * FROM (SELECT * FROM events WHERE owner = $1) AS page WHERE (created_at, id) < ($2, $3) ORDER BY created_at DESC, id DESC LIMIT 11"), q)
+	assert.Equal(t, []any{"t", 5}, args)
+
+	q, _ = paginationQuery("SELECT * FROM events", []SortKey{Asc("name"), Desc("id")}, 10, true, []any{"x", 5}, 0)
+	assert.Equal(t, SQL("SELECT * FROM (SELECT * FROM events) AS page WHERE (name < $1) OR (name = $1 AND id > $2) ORDER BY name DESC, id LIMIT 11"), q)
+}
+
+func TestPaginateRejectsQueryOptions(t *testing.T) {
+	sort := []SortKey{Asc("id")}
+	assert.PanicsWithError(t, "pagination arguments must be positional parameters, got pgxx.Fragment", func() {
+		Paginate[Event](context.Background(), nil, "SELECT * FROM events", sort, 10, "", Where(Frag("owner = $1", 1)))
+	})
+	assert.PanicsWithError(t, "pagination arguments must be positional parameters, got pgx.QueryExecMode", func() {
+		Paginate[Event](context.Background(), nil, "SELECT * FROM events WHERE owner = $1", sort, 10, "", pgx.QueryExecModeSimpleProtocol, 1)
+	})
+}
+
+func TestPageTokenRoundTrip(t *testing.T) {
+	mapping := structMappingFor[Event]()
+	sort := []SortKey{Desc("created_at"), Desc("id")}
+	ev := Event{ID: 42, CreatedAt: time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC), Name: "launch"}
+
+	token := encodePageToken(mapping, sort, &ev, true)
+	backward, values, err := decodePageToken(mapping, sort, token)
+	assert.NoError(t, err)
+	assert.True(t, backward)
+	assert.Equal(t, []any{ev.CreatedAt, 42}, values)
+
+	// tokens are rejected when used with a different sort order or tampered with
+	_, _, err = decodePageToken(mapping, []SortKey{Desc("id")}, token)
+	assert.ErrorIs(t, err, ErrInvalidPageToken)
+	_, _, err = decodePageToken(mapping, sort, "not a token")
+	assert.ErrorIs(t, err, ErrInvalidPageToken)
+}
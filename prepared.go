@@ -0,0 +1,133 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Connection on which statements can be prepared, such as a *pgx.Conn or a Tx.
+// Prepared statements belong to a single connection, so pools must be used through an acquired connection.
+type Preparer interface {
+	Prepare(ctx context.Context, name, sql string) (*pgconn.StatementDescription, error)
+}
+
+// A statement with named parameters prepared on a connection, taking its parameters from values of type A
+// (a struct, pointer to struct, or map).
+// The statement is referred to by name, so can only be used on the connection it was prepared on
+// (and transactions and batches on it).
+type NamedStmt[A any] struct {
+	Name string
+	// The query with named parameters replaced by positional ones
+	SQL         SQL
+	Description *pgconn.StatementDescription
+	fields      []FieldName
+}
+
+// Rewrites a query with named parameters and prepares it on a connection under the given name.
+// If A is a struct (or pointer to struct), checks that it has a field for every parameter.
+// Expanded parameters (such as `@ids...`) and positional parameters cannot be used,
+// as the number of parameters of a prepared statement is fixed.
+func PrepareNamed[A any](ctx context.Context, conn Preparer, name string, namedQuery SQL) (*NamedStmt[A], error) {
+	parsed := parsedQueryOf(namedQuery)
+	if parsed.err != nil {
+		return nil, parsed.err
+	} else if parsed.hasExpansion {
+		return nil, errors.New("prepared statements cannot use expanded parameters")
+	} else if parsed.maxOrdinal > 0 {
+		return nil, errors.New("prepared statements cannot mix named and positional parameters")
+	}
+
+	t := reflect.TypeFor[A]()
+	if isMappable(t) {
+		mapping := structMappingOf(t)
+		for _, f := range parsed.fields {
+			if _, found := mapping.FieldMappings[f]; found {
+				continue
+			}
+			if flat := mapping.DottedNames[f]; flat != "" {
+				continue
+			}
+			return nil, fmt.Errorf("missing database field %s in struct %s", f, mapping.StructType.Name())
+		}
+	} else if t.Kind() != reflect.Map && t.Kind() != reflect.Interface {
+		return nil, fmt.Errorf("cannot take named parameters from %v", t)
+	}
+
+	query := parsed.positional(0)
+	desc, err := conn.Prepare(ctx, name, string(query))
+	if err != nil {
+		return nil, err
+	}
+	return &NamedStmt[A]{
+		Name:        name,
+		SQL:         query,
+		Description: desc,
+		fields:      parsed.fields,
+	}, nil
+}
+
+// Extracts the positional parameters of the statement from a value.
+func (s *NamedStmt[A]) Args(args A) ([]any, error) {
+	return extractNamedArgs(s.fields, args)
+}
+
+// Runs the statement and returns the number of rows affected.
+func (s *NamedStmt[A]) Exec(ctx context.Context, conn PoolOrTx, args A) (int, error) {
+	posArgs, err := s.Args(args)
+	if err != nil {
+		return 0, err
+	}
+	return Exec(ctx, conn, SQL(s.Name), posArgs...)
+}
+
+// Version of Exec which queues to a batch.
+// If out is not nil, writes the number of rows affected there when the batch is run.
+// Panics if the parameters cannot be extracted.
+func (s *NamedStmt[A]) QueueExec(batch *pgx.Batch, out *int, args A) {
+	posArgs, err := s.Args(args)
+	if err != nil {
+		panic(err)
+	}
+	QueueExec(batch, out, SQL(s.Name), posArgs...)
+}
+
+// Runs a prepared statement and reads out the results as a slice of
+// either structs (for multiple-column queries) or primitives (for single-column queries only).
+func QueryStmt[T, A any](ctx context.Context, conn PoolOrTx, stmt *NamedStmt[A], args A) ([]T, error) {
+	posArgs, err := stmt.Args(args)
+	if err != nil {
+		return nil, err
+	}
+	return Query[T](ctx, conn, SQL(stmt.Name), posArgs...)
+}
+
+// Runs a prepared statement that returns at most one row and reads out the result
+// as a struct (for multiple-column queries) or a primitive (for single-column queries only).
+// Returns the zero value if the query produces no rows. Discards if multiple rows are produced.
+func QueryOneStmt[T, A any](ctx context.Context, conn PoolOrTx, stmt *NamedStmt[A], args A) (T, error) {
+	posArgs, err := stmt.Args(args)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return QueryOne[T](ctx, conn, SQL(stmt.Name), posArgs...)
+}
+
+// Version of QueryStmt which queues to a batch.
+// Writes results into *out (which must not be nil) when the batch is run.
+// Panics if the parameters cannot be extracted.
+func QueueQueryStmt[T, A any](batch *pgx.Batch, out *[]T, stmt *NamedStmt[A], args A) {
+	posArgs, err := stmt.Args(args)
+	if err != nil {
+		panic(err)
+	}
+	QueueQuery(batch, out, SQL(stmt.Name), posArgs...)
+}
@@ -0,0 +1,51 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePreparer map[string]string
+
+func (p fakePreparer) Prepare(ctx context.Context, name, sql string) (*pgconn.StatementDescription, error) {
+	p[name] = sql
+	return &pgconn.StatementDescription{Name: name, SQL: sql}, nil
+}
+
+func TestPrepareNamed(t *testing.T) {
+	ctx := context.Background()
+	conn := fakePreparer{}
+
+	stmt, err := PrepareNamed[*Foo2](ctx, conn, "update_foo", "UPDATE foo SET b = @b, bar_c = @bar.c WHERE a = @a")
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE foo SET b = $1, bar_c = $2 WHERE a = $3", conn["update_foo"])
+	args, err := stmt.Args(&Foo2{A: 1, B: "b", Bar2: &Bar{C: 1.5}})
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"b", 1.5, 1}, args)
+
+	batch := &pgx.Batch{}
+	stmt.QueueExec(batch, nil, &Foo2{A: 2})
+	assert.Equal(t, "update_foo", batch.QueuedQueries[0].SQL)
+	assert.Equal(t, []any{"", nil, 2}, batch.QueuedQueries[0].Arguments)
+
+	// maps are checked when binding rather than when preparing
+	mapStmt, err := PrepareNamed[pgx.NamedArgs](ctx, conn, "select_foo", "SELECT * FROM foo WHERE a = @a")
+	assert.NoError(t, err)
+	_, err = mapStmt.Args(pgx.NamedArgs{})
+	assert.EqualError(t, err, "missing database field a in map")
+
+	_, err = PrepareNamed[Foo](ctx, conn, "bad", "SELECT @a, @d")
+	assert.EqualError(t, err, "missing database field d in struct Foo")
+	_, err = PrepareNamed[Foo](ctx, conn, "bad", "SELECT * FROM foo WHERE a IN (@a...)")
+	assert.EqualError(t, err, "prepared statements cannot use expanded parameters")
+	_, err = PrepareNamed[int](ctx, conn, "bad", "SELECT @a")
+	assert.EqualError(t, err, "cannot take named parameters from int")
+	assert.NotContains(t, conn, "bad")
+}
@@ -5,6 +5,7 @@ package pgxx
 
 import (
 	"context"
+	"iter"
 	"strings"
 
 	"github.com/jackc/pgx/v5"
@@ -78,12 +79,17 @@ func NamedExecExactlyOne(ctx context.Context, conn PoolOrTx, namedQuery SQL, arg
 // Run a query with positional parameters and read out the results as a slice of
 // either structs (for multiple-column queries) or primitives (for single-column queries only).
 func Query[T any](ctx context.Context, conn PoolOrTx, query SQL, args ...any) ([]T, error) {
+	return QueryWithOptions[T](ctx, conn, DefaultScanOptions, query, args...)
+}
+
+// Version of Query with explicit options for matching columns to struct fields.
+func QueryWithOptions[T any](ctx context.Context, conn PoolOrTx, opts ScanOptions, query SQL, args ...any) ([]T, error) {
 	cursor, err := conn.Query(ctx, string(query), args...)
 	if err != nil {
 		return nil, err
 	}
 	var out []T
-	err = ScanRows(cursor, &out)
+	err = ScanRowsWithOptions(cursor, &out, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -94,17 +100,38 @@ func Query[T any](ctx context.Context, conn PoolOrTx, query SQL, args ...any) ([
 // read out the results as a slice of either structs (for multiple-column queries)
 // or primitives (for single-column queries only).
 func NamedQuery[T any](ctx context.Context, conn PoolOrTx, namedQuery SQL, argsStruct any) ([]T, error) {
+	return NamedQueryWithOptions[T](ctx, conn, DefaultScanOptions, namedQuery, argsStruct)
+}
+
+// Version of NamedQuery with explicit options for matching columns to struct fields.
+func NamedQueryWithOptions[T any](ctx context.Context, conn PoolOrTx, opts ScanOptions, namedQuery SQL, argsStruct any) ([]T, error) {
 	query, args := ExtractNamedQuery(namedQuery, argsStruct)
-	cursor, err := conn.Query(ctx, string(query), args...)
-	if err != nil {
-		return nil, err
-	}
-	var out []T
-	err = ScanRows(cursor, &out)
-	if err != nil {
-		return nil, err
+	return QueryWithOptions[T](ctx, conn, opts, query, args...)
+}
+
+// Run a query with positional parameters and stream the results as a sequence of
+// either structs (for multiple-column queries) or primitives (for single-column queries only).
+// The query is sent each time the sequence is iterated, and the cursor is closed when the loop ends or exits early.
+// Errors (including those from sending the query) are yielded along with a zero value and end the iteration.
+func QueryIter[T any](ctx context.Context, conn PoolOrTx, query SQL, args ...any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		cursor, err := conn.Query(ctx, string(query), args...)
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+		IterRows[T](cursor)(yield)
 	}
-	return out, nil
+}
+
+// Run a query with named parameters (pulling them out of a struct) and stream the results as a sequence of
+// either structs (for multiple-column queries) or primitives (for single-column queries only).
+// Parameters are extracted immediately, but the query is sent each time the sequence is iterated.
+// Errors (including those from sending the query) are yielded along with a zero value and end the iteration.
+func NamedQueryIter[T any](ctx context.Context, conn PoolOrTx, namedQuery SQL, argsStruct any) iter.Seq2[T, error] {
+	query, args := ExtractNamedQuery(namedQuery, argsStruct)
+	return QueryIter[T](ctx, conn, query, args...)
 }
 
 // Run a query with positional parameters that returns at most one row and
@@ -173,6 +200,27 @@ func NamedQueryExactlyOne[T any](ctx context.Context, conn PoolOrTx, namedQuery
 	return out, nil
 }
 
+// Version of NamedExec for queries which also use positional parameters (such as $1) with values given by extra,
+// numbering named parameters after the highest positional one.
+func NamedExecWithArgs(ctx context.Context, conn PoolOrTx, namedQuery SQL, argsStruct any, extra ...any) (int, error) {
+	query, args := ExtractNamedQueryWithArgs(namedQuery, argsStruct, extra...)
+	return Exec(ctx, conn, query, args...)
+}
+
+// Version of NamedQuery for queries which also use positional parameters (such as $1) with values given by extra,
+// numbering named parameters after the highest positional one.
+func NamedQueryWithArgs[T any](ctx context.Context, conn PoolOrTx, namedQuery SQL, argsStruct any, extra ...any) ([]T, error) {
+	query, args := ExtractNamedQueryWithArgs(namedQuery, argsStruct, extra...)
+	return Query[T](ctx, conn, query, args...)
+}
+
+// Version of NamedQueryOne for queries which also use positional parameters (such as $1) with values given by extra,
+// numbering named parameters after the highest positional one.
+func NamedQueryOneWithArgs[T any](ctx context.Context, conn PoolOrTx, namedQuery SQL, argsStruct any, extra ...any) (T, error) {
+	query, args := ExtractNamedQueryWithArgs(namedQuery, argsStruct, extra...)
+	return QueryOne[T](ctx, conn, query, args...)
+}
+
 // Runs a COPY FROM STDIN query for bulk insertion, with the records to insert passed in as structs.
 func NamedCopyFrom[T any](ctx context.Context, conn PoolOrTx, tableName SQL, fields []FieldName, records []T) (int, error) {
 	var pgxFields []string
@@ -0,0 +1,74 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+// LRU cache of parsed named queries.
+var queryCache = newLRUCache[SQL, *parsedQuery](1024)
+
+// Sets the maximum number of parsed named queries to cache (1024 by default), evicting the least recently used.
+// Set to 0 to disable caching. Safe to call at any time.
+func SetQueryCacheSize(size int) {
+	queryCache.setSize(size)
+}
+
+// Gets the parsed form of a named query, lexing it only if it is not in the cache.
+// Parsed queries are never modified, so can be shared between goroutines.
+func parsedQueryOf(query SQL) *parsedQuery {
+	if parsed, ok := queryCache.get(query); ok {
+		return parsed
+	}
+	// concurrent misses for the same query produce equivalent results
+	parsed := parseNamedQuery(query)
+	queryCache.put(query, parsed)
+	return parsed
+}
+
+// Statistics on the caches used to avoid repeated reflection and parsing.
+type CacheStats struct {
+	// Number of named queries currently cached
+	Queries int
+	// Number of named queries found in and missing from the cache, and removed to make room for others,
+	// since the last reset
+	QueryHits, QueryMisses, QueryEvictions uint64
+	// Number of struct types whose mapping is cached
+	StructMappings int
+	// Number of scan plans (for a struct, tuple, or grouped type and list of columns) currently cached
+	ScanPlans int
+	// Number of scan plans found in and missing from the cache, and removed to make room for others,
+	// since the last reset
+	ScanPlanHits, ScanPlanMisses, ScanPlanEvictions uint64
+}
+
+// Returns statistics on the caches of parsed queries, struct mappings, and scan plans.
+func GetCacheStats() CacheStats {
+	var stats CacheStats
+
+	stats.Queries = queryCache.len()
+	stats.QueryHits = queryCache.hits.Load()
+	stats.QueryMisses = queryCache.misses.Load()
+	stats.QueryEvictions = queryCache.evictions.Load()
+
+	structMappingsLock.RLock()
+	stats.StructMappings = len(structMappingsCache)
+	structMappingsLock.RUnlock()
+
+	stats.ScanPlans = scanPlans.len()
+	stats.ScanPlanHits = scanPlans.hits.Load()
+	stats.ScanPlanMisses = scanPlans.misses.Load()
+	stats.ScanPlanEvictions = scanPlans.evictions.Load()
+
+	return stats
+}
+
+// Clears the caches of parsed queries, struct mappings, and scan plans (along with their statistics).
+// This is only needed to free memory after using many dynamically-generated queries or types.
+func ResetCaches() {
+	queryCache.reset()
+
+	structMappingsLock.Lock()
+	clear(structMappingsCache)
+	structMappingsLock.Unlock()
+
+	scanPlans.reset()
+}
@@ -0,0 +1,128 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryCache(t *testing.T) {
+	defer SetQueryCacheSize(queryCache.size)
+	SetQueryCacheSize(2)
+	ResetCaches()
+
+	ExtractNamedQuery("SELECT @a", Foo{})
+	ExtractNamedQuery("SELECT @b", Foo{})
+	ExtractNamedQuery("SELECT @a", Foo{})
+	// evicts SELECT @b as the least recently used
+	ExtractNamedQuery("SELECT @c", Foo{})
+	ExtractNamedQuery("SELECT @a", Foo{})
+
+	stats := GetCacheStats()
+	assert.Equal(t, 2, stats.Queries)
+	assert.Equal(t, uint64(2), stats.QueryHits)
+	assert.Equal(t, uint64(3), stats.QueryMisses)
+	assert.Equal(t, uint64(1), stats.QueryEvictions)
+	assert.Equal(t, 1, stats.StructMappings)
+
+	// cached field lists are not shared with callers
+	_, fields := RewriteNamedQuery("SELECT @a")
+	fields[0] = "b"
+	query, args := ExtractNamedQuery("SELECT @a", Foo{A: 1})
+	assert.Equal(t, SQL("SELECT $1"), query)
+	assert.Equal(t, []any{1}, args)
+
+	// shrinking the cache evicts immediately
+	SetQueryCacheSize(1)
+	stats = GetCacheStats()
+	assert.Equal(t, 1, stats.Queries)
+	assert.Equal(t, uint64(2), stats.QueryEvictions)
+
+	ResetCaches()
+	assert.Equal(t, CacheStats{}, GetCacheStats())
+}
+
+func TestQueryCacheConcurrent(t *testing.T) {
+	defer SetQueryCacheSize(queryCache.size)
+	SetQueryCacheSize(4)
+	ResetCaches()
+
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				query := SQL(fmt.Sprintf("SELECT @a, %d", (g+i)%6))
+				posQuery, _ := ExtractNamedQuery(query, Foo{})
+				assert.Equal(t, SQL(fmt.Sprintf("SELECT $1, %d", (g+i)%6)), posQuery)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := GetCacheStats()
+	assert.Equal(t, 4, stats.Queries)
+	assert.Equal(t, uint64(800), stats.QueryHits+stats.QueryMisses)
+	// concurrent misses for the same query only add it once
+	assert.LessOrEqual(t, stats.QueryEvictions, stats.QueryMisses-4)
+}
+
+func TestScanPlanCache(t *testing.T) {
+	defer SetScanPlanCacheSize(scanPlans.size)
+	SetScanPlanCacheSize(2)
+	ResetCaches()
+
+	cols := []fakeCol{{"a", pgtype.Int8OID}, {"b", pgtype.TextOID}, {"c", pgtype.Float8OID}}
+	var foos []Foo
+	var tuples []Tuple2[Foo, Bar]
+	var groups []groupedPart
+	for range 2 {
+		assert.NoError(t, ScanRows(newFakeRows(cols), &foos))
+		assert.NoError(t, ScanTuples(newFakeRows(cols), SplitAt(2), &tuples))
+	}
+	stats := GetCacheStats()
+	assert.Equal(t, 2, stats.ScanPlans)
+	assert.Equal(t, uint64(2), stats.ScanPlanHits)
+	assert.Equal(t, uint64(2), stats.ScanPlanMisses)
+
+	// plans for different splits of the same columns are cached separately, evicting the least recently used
+	assert.NoError(t, ScanTuples(newFakeRows(cols), SplitAt(3), &tuples))
+	assert.NoError(t, ScanRowsGrouped(newFakeRows([]fakeCol{{"name", pgtype.TextOID}}), &groups))
+	stats = GetCacheStats()
+	assert.Equal(t, 2, stats.ScanPlans)
+	assert.Equal(t, uint64(4), stats.ScanPlanMisses)
+	assert.Equal(t, uint64(2), stats.ScanPlanEvictions)
+}
+
+// A typical request-path query with a handful of parameters
+const benchNamedQuery SQL = `SELECT a, b, c FROM foo
+	WHERE a = @a AND b = @b -- match on both keys
+	AND c > @c AND 'literal @text' IS NOT NULL
+	ORDER BY a LIMIT 10`
+
+func BenchmarkExtractNamedQuery(b *testing.B) {
+	foo := Foo{A: 1, B: "b", Bar: Bar{C: 1.5}}
+	b.ReportAllocs()
+	for range b.N {
+		ExtractNamedQuery(benchNamedQuery, &foo)
+	}
+}
+
+func BenchmarkExtractNamedQueryUncached(b *testing.B) {
+	defer SetQueryCacheSize(queryCache.size)
+	SetQueryCacheSize(0)
+	ResetCaches()
+
+	foo := Foo{A: 1, B: "b", Bar: Bar{C: 1.5}}
+	b.ReportAllocs()
+	for range b.N {
+		ExtractNamedQuery(benchNamedQuery, &foo)
+	}
+}
@@ -5,8 +5,12 @@
 package pgxx
 
 import (
+	"fmt"
+	"reflect"
+	"slices"
 	"strconv"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -19,55 +23,219 @@ import (
 //	conn.Query(ctx, "select * from widgets where foo = $1 and bar = $2", 1, 2)
 //
 // Named placeholders are case sensitive and must start with a letter or underscore. Subsequent characters can be
-// letters, numbers, or underscores.
+// letters, numbers, or underscores. Letters may be any Unicode letter.
+// Names may also be dotted paths (such as @user.name), which refer to fields inside db_prefix structs.
+// Placeholders are not replaced inside quoted strings and identifiers, comments, or dollar-quoted strings (such as function bodies).
 
 type namedArg string
 
+// A named parameter followed by `...`, which is expanded into one placeholder per element of a slice.
+type expandedArg string
+
+// A positional parameter such as $1, along with its original text.
+type positionalArg struct {
+	text    string
+	ordinal int
+}
+
 type sqlLexer struct {
-	src     string
-	start   int
-	pos     int
-	nested  int // multiline comment nesting level.
-	stateFn stateFn
-	parts   []any
-
-	nameToOrdinal map[namedArg]int
+	src    string
+	start  int
+	pos    int
+	nested int // multiline comment nesting level.
+	// delimiter of the current dollar-quoted string, including both $.
+	dollarTag string
+	// offset of the quote or comment being lexed, and its description if the query ends inside it.
+	constructStart int
+	unterminated   string
+	maxOrdinal     int // highest positional parameter ($n) used
+	stateFn        stateFn
+	parts          []any
 }
 
 type stateFn func(*sqlLexer) stateFn
 
-func RewriteNamedQuery(namedQuery SQL) (SQL, []FieldName) {
+// A segment of a parsed query: either literal text or a reference to a parameter.
+type queryPart struct {
+	text    string
+	field   int // index into fields, or -1 for text
+	expand  bool
+	ordinal int // for positional parameters (which are otherwise treated as text)
+}
+
+// A named query split into literal text and parameters, which can be rendered with positional parameters.
+type parsedQuery struct {
+	parts  []queryPart
+	fields []FieldName // distinct parameter names in order of first use
+	// whether each parameter is used as a single value, and whether it is used as an expanded list
+	plain, expanded []bool
+	hasExpansion    bool
+	maxOrdinal      int // highest positional parameter ($n) in the query itself
+	// set if the query ends inside a quote or comment, in which case parameters after it are not found
+	err *QuerySyntaxError
+}
+
+// Error for a named query ending inside a quoted string, quoted identifier, or block comment.
+// The location is that of the start of the unterminated construct, with lines and columns counted from 1
+// (columns in characters).
+type QuerySyntaxError struct {
+	Offset    int
+	Line      int
+	Column    int
+	Construct string
+}
+
+func (e *QuerySyntaxError) Error() string {
+	return fmt.Sprintf("unterminated %s starting at line %d, column %d (offset %d)", e.Construct, e.Line, e.Column, e.Offset)
+}
+
+func newQuerySyntaxError(src string, offset int, construct string) *QuerySyntaxError {
+	before := src[:offset]
+	lineStart := strings.LastIndexByte(before, '\n') + 1
+	return &QuerySyntaxError{
+		Offset:    offset,
+		Line:      strings.Count(before, "\n") + 1,
+		Column:    utf8.RuneCountInString(before[lineStart:]) + 1,
+		Construct: construct,
+	}
+}
+
+func parseNamedQuery(namedQuery SQL) *parsedQuery {
 	l := &sqlLexer{
-		src:           string(namedQuery),
-		stateFn:       rawState,
-		nameToOrdinal: make(map[namedArg]int),
+		src:     string(namedQuery),
+		stateFn: rawState,
 	}
 
 	for l.stateFn != nil {
 		l.stateFn = l.stateFn(l)
 	}
 
-	sb := strings.Builder{}
+	q := &parsedQuery{maxOrdinal: l.maxOrdinal}
+	if l.unterminated != "" {
+		q.err = newQuerySyntaxError(l.src, l.constructStart, l.unterminated)
+	}
+	nameToIndex := make(map[FieldName]int)
+	indexOf := func(name FieldName) int {
+		i, found := nameToIndex[name]
+		if !found {
+			i = len(q.fields)
+			nameToIndex[name] = i
+			q.fields = append(q.fields, name)
+			q.plain = append(q.plain, false)
+			q.expanded = append(q.expanded, false)
+		}
+		return i
+	}
 	for _, p := range l.parts {
 		switch p := p.(type) {
 		case string:
-			sb.WriteString(p)
+			q.parts = append(q.parts, queryPart{text: p, field: -1})
+		case positionalArg:
+			q.parts = append(q.parts, queryPart{text: p.text, field: -1, ordinal: p.ordinal})
 		case namedArg:
+			i := indexOf(FieldName(p))
+			q.plain[i] = true
+			q.parts = append(q.parts, queryPart{field: i})
+		case expandedArg:
+			i := indexOf(FieldName(p))
+			q.expanded[i] = true
+			q.hasExpansion = true
+			q.parts = append(q.parts, queryPart{field: i, expand: true})
+		}
+	}
+	return q
+}
+
+// Renders a parsed query using the ordinal of each parameter (after the first offset) as its placeholder,
+// ignoring expansion.
+func (q *parsedQuery) positional(offset int) SQL {
+	sb := strings.Builder{}
+	for _, p := range q.parts {
+		if p.field < 0 {
+			sb.WriteString(p.text)
+		} else {
 			sb.WriteRune('$')
-			sb.WriteString(strconv.Itoa(l.nameToOrdinal[p]))
+			sb.WriteString(strconv.Itoa(offset + p.field + 1))
+		}
+	}
+	return SQL(sb.String())
+}
+
+// Renders a parsed query given the values of its parameters (in the order of fields),
+// numbering placeholders after the first offset (which are left for other parameters).
+// Expanded parameters must be non-empty slices or arrays and are replaced by one placeholder per element,
+// as an empty list cannot be written in a way that is correct for both IN and NOT IN.
+func (q *parsedQuery) render(args []any, offset int) (SQL, []any, error) {
+	if !q.hasExpansion {
+		return q.positional(offset), args, nil
+	}
+
+	var outArgs []any
+	plainOrdinal := make([]int, len(q.fields))
+	expandedOrdinal := make([]int, len(q.fields))
+	expandedLen := make([]int, len(q.fields))
+	for i, f := range q.fields {
+		if q.plain[i] {
+			outArgs = append(outArgs, args[i])
+			plainOrdinal[i] = offset + len(outArgs)
+		}
+		if q.expanded[i] {
+			v := reflect.ValueOf(args[i])
+			if args[i] == nil || ((v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Len() == 0) {
+				return "", nil, fmt.Errorf("expanded parameter %s is empty (compare using = ANY(@%s) to allow empty slices)", f, f)
+			} else if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+				return "", nil, fmt.Errorf("expanded parameter %s must be a slice, got %T", f, args[i])
+			}
+			expandedOrdinal[i] = offset + len(outArgs) + 1
+			expandedLen[i] = v.Len()
+			for j := range v.Len() {
+				outArgs = append(outArgs, v.Index(j).Interface())
+			}
 		}
 	}
 
-	newArgs := make([]FieldName, len(l.nameToOrdinal))
-	for name, ordinal := range l.nameToOrdinal {
-		newArgs[ordinal-1] = FieldName(name)
+	sb := strings.Builder{}
+	for _, p := range q.parts {
+		if p.field < 0 {
+			sb.WriteString(p.text)
+		} else if !p.expand {
+			sb.WriteRune('$')
+			sb.WriteString(strconv.Itoa(plainOrdinal[p.field]))
+		} else {
+			for j := range expandedLen[p.field] {
+				if j > 0 {
+					sb.WriteString(", ")
+				}
+				sb.WriteRune('$')
+				sb.WriteString(strconv.Itoa(expandedOrdinal[p.field] + j))
+			}
+		}
 	}
+	return SQL(sb.String()), outArgs, nil
+}
 
-	return SQL(sb.String()), newArgs
+// Converts a query with named parameters to one with positional parameters,
+// returning the names of the parameters in order.
+// Parameters marked for expansion (such as `@ids...`) are rendered as a single placeholder
+// (as their length is not known), so queries using them should be run through [ExtractNamedQuery] instead.
+func RewriteNamedQuery(namedQuery SQL) (SQL, []FieldName) {
+	q := parsedQueryOf(namedQuery)
+	return q.positional(0), slices.Clone(q.fields)
+}
+
+// Version of RewriteNamedQuery which returns a [*QuerySyntaxError] if the query ends inside a quote or block comment
+// (which would otherwise hide any parameters after its start).
+func TryRewriteNamedQuery(namedQuery SQL) (SQL, []FieldName, error) {
+	q := parsedQueryOf(namedQuery)
+	if q.err != nil {
+		return "", nil, q.err
+	}
+	return q.positional(0), slices.Clone(q.fields), nil
 }
 
 func rawState(l *sqlLexer) stateFn {
 	for {
+		l.constructStart = l.pos
 		r, width := utf8.DecodeRuneInString(l.src[l.pos:])
 		l.pos += width
 
@@ -82,6 +250,27 @@ func rawState(l *sqlLexer) stateFn {
 			return singleQuoteState
 		case '"':
 			return doubleQuoteState
+		case '$':
+			prevRune, _ := utf8.DecodeLastRuneInString(l.src[:l.pos-width])
+			if !isIdentRune(prevRune) {
+				if tagLen := dollarTagLen(l.src[l.pos:]); tagLen >= 0 {
+					l.dollarTag = l.src[l.pos-width : l.pos+tagLen+1]
+					l.pos += tagLen + 1
+					return dollarQuoteState
+				}
+				// record positional parameters, to number named ones after them when mixing them
+				// and to renumber them when combining fragments
+				digits := len(l.src[l.pos:]) - len(strings.TrimLeft(l.src[l.pos:], "0123456789"))
+				if ordinal, err := strconv.Atoi(l.src[l.pos : l.pos+digits]); err == nil {
+					if l.pos-width > l.start {
+						l.parts = append(l.parts, l.src[l.start:l.pos-width])
+					}
+					l.pos += digits
+					l.parts = append(l.parts, positionalArg{text: l.src[l.pos-width-digits : l.pos], ordinal: ordinal})
+					l.start = l.pos
+					l.maxOrdinal = max(l.maxOrdinal, ordinal)
+				}
+			}
 		case '@':
 			nextRune, _ := utf8.DecodeRuneInString(l.src[l.pos:])
 			if isLetter(nextRune) || nextRune == '_' {
@@ -104,6 +293,10 @@ func rawState(l *sqlLexer) stateFn {
 				return multilineCommentState
 			}
 		case utf8.RuneError:
+			if width != 0 {
+				// invalid UTF-8 or a literal U+FFFD rather than the end of the query
+				continue
+			}
 			if l.pos-l.start > 0 {
 				l.parts = append(l.parts, l.src[l.start:l.pos])
 				l.start = l.pos
@@ -114,7 +307,25 @@ func rawState(l *sqlLexer) stateFn {
 }
 
 func isLetter(r rune) bool {
-	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+	return unicode.IsLetter(r)
+}
+
+// Whether a rune can continue an unquoted identifier (in which case a following $ does not start a dollar quote).
+func isIdentRune(r rune) bool {
+	return isLetter(r) || (r >= '0' && r <= '9') || r == '_' || r == '$'
+}
+
+// Returns the length of the tag of a dollar quote (such as `$tag$` or `$$`) following an initial $,
+// or -1 if there is none (such as for a positional parameter like $1).
+func dollarTagLen(src string) int {
+	for i, r := range src {
+		if r == '$' {
+			return i
+		} else if !(isLetter(r) || r == '_' || (i > 0 && r >= '0' && r <= '9')) {
+			return -1
+		}
+	}
+	return -1
 }
 
 func namedArgState(l *sqlLexer) stateFn {
@@ -122,23 +333,24 @@ func namedArgState(l *sqlLexer) stateFn {
 		r, width := utf8.DecodeRuneInString(l.src[l.pos:])
 		l.pos += width
 
-		if r == utf8.RuneError {
+		if width == 0 {
 			if l.pos-l.start > 0 {
-				na := namedArg(l.src[l.start:l.pos])
-				if _, found := l.nameToOrdinal[na]; !found {
-					l.nameToOrdinal[na] = len(l.nameToOrdinal) + 1
-				}
-				l.parts = append(l.parts, na)
+				l.parts = append(l.parts, namedArg(l.src[l.start:l.pos]))
 				l.start = l.pos
 			}
 			return nil
+		} else if nextRune, _ := utf8.DecodeRuneInString(l.src[l.pos:]); r == '.' && (isLetter(nextRune) || nextRune == '_') {
+			// continue a dotted path through prefixed structs
+			continue
 		} else if !(isLetter(r) || (r >= '0' && r <= '9') || r == '_') {
 			l.pos -= width
 			na := namedArg(l.src[l.start:l.pos])
-			if _, found := l.nameToOrdinal[na]; !found {
-				l.nameToOrdinal[na] = len(l.nameToOrdinal) + 1
+			if strings.HasPrefix(l.src[l.pos:], "...") {
+				l.parts = append(l.parts, expandedArg(na))
+				l.pos += len("...")
+			} else {
+				l.parts = append(l.parts, na)
 			}
-			l.parts = append(l.parts, namedArg(na))
 			l.start = l.pos
 			return rawState
 		}
@@ -158,10 +370,14 @@ func singleQuoteState(l *sqlLexer) stateFn {
 			}
 			l.pos += width
 		case utf8.RuneError:
+			if width != 0 {
+				continue
+			}
 			if l.pos-l.start > 0 {
 				l.parts = append(l.parts, l.src[l.start:l.pos])
 				l.start = l.pos
 			}
+			l.unterminated = "string literal"
 			return nil
 		}
 	}
@@ -180,15 +396,34 @@ func doubleQuoteState(l *sqlLexer) stateFn {
 			}
 			l.pos += width
 		case utf8.RuneError:
+			if width != 0 {
+				continue
+			}
 			if l.pos-l.start > 0 {
 				l.parts = append(l.parts, l.src[l.start:l.pos])
 				l.start = l.pos
 			}
+			l.unterminated = "quoted identifier"
 			return nil
 		}
 	}
 }
 
+func dollarQuoteState(l *sqlLexer) stateFn {
+	end := strings.Index(l.src[l.pos:], l.dollarTag)
+	if end < 0 {
+		l.pos = len(l.src)
+		if l.pos-l.start > 0 {
+			l.parts = append(l.parts, l.src[l.start:l.pos])
+			l.start = l.pos
+		}
+		l.unterminated = "dollar-quoted string"
+		return nil
+	}
+	l.pos += end + len(l.dollarTag)
+	return rawState
+}
+
 func escapeStringState(l *sqlLexer) stateFn {
 	for {
 		r, width := utf8.DecodeRuneInString(l.src[l.pos:])
@@ -205,10 +440,14 @@ func escapeStringState(l *sqlLexer) stateFn {
 			}
 			l.pos += width
 		case utf8.RuneError:
+			if width != 0 {
+				continue
+			}
 			if l.pos-l.start > 0 {
 				l.parts = append(l.parts, l.src[l.start:l.pos])
 				l.start = l.pos
 			}
+			l.unterminated = "string literal"
 			return nil
 		}
 	}
@@ -226,6 +465,9 @@ func oneLineCommentState(l *sqlLexer) stateFn {
 		case '\n', '\r':
 			return rawState
 		case utf8.RuneError:
+			if width != 0 {
+				continue
+			}
 			if l.pos-l.start > 0 {
 				l.parts = append(l.parts, l.src[l.start:l.pos])
 				l.start = l.pos
@@ -260,10 +502,14 @@ func multilineCommentState(l *sqlLexer) stateFn {
 			l.nested--
 
 		case utf8.RuneError:
+			if width != 0 {
+				continue
+			}
 			if l.pos-l.start > 0 {
 				l.parts = append(l.parts, l.src[l.start:l.pos])
 				l.start = l.pos
 			}
+			l.unterminated = "block comment"
 			return nil
 		}
 	}
@@ -0,0 +1,125 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewriteNamedQueryQuoting(t *testing.T) {
+	cases := []struct {
+		named    SQL
+		expected SQL
+		fields   []FieldName
+	}{
+		{`SELECT @a, '@b', "@c", E'\'@d', @a -- @e`, `SELECT $1, '@b', "@c", E'\'@d', $1 -- @e`, []FieldName{"a"}},
+		{`SELECT /* @a /* @b */ @c */ @d`, `SELECT /* @a /* @b */ @c */ $1`, []FieldName{"d"}},
+		{`DO $$ BEGIN PERFORM @a; END $$; SELECT @b`, `DO $$ BEGIN PERFORM @a; END $$; SELECT $1`, []FieldName{"b"}},
+		{`SELECT $fn$ @a $$ @b $fn$, @c`, `SELECT $fn$ @a $$ @b $fn$, $1`, []FieldName{"c"}},
+		// not dollar quotes: positional parameters and identifiers containing $
+		{`SELECT $1, @a, foo$bar$ @b`, `SELECT $1, $1, foo$bar$ $2`, []FieldName{"a", "b"}},
+		{`SELECT @prénom, @名前`, `SELECT $1, $2`, []FieldName{"prénom", "名前"}},
+		{`SELECT '�' || @a`, `SELECT '�' || $1`, []FieldName{"a"}},
+		{`SELECT @u.name, @v., @ids...`, `SELECT $1, $2., $3`, []FieldName{"u.name", "v", "ids"}},
+	}
+	for _, c := range cases {
+		query, fields := RewriteNamedQuery(c.named)
+		assert.Equal(t, c.expected, query)
+		assert.Equal(t, c.fields, fields)
+	}
+}
+
+func TestQuerySyntaxErrors(t *testing.T) {
+	cases := []struct {
+		named    SQL
+		expected QuerySyntaxError
+	}{
+		{"SELECT 'abc @a", QuerySyntaxError{Offset: 7, Line: 1, Column: 8, Construct: "string literal"}},
+		{"SELECT @a,\n  E'it''s @b", QuerySyntaxError{Offset: 13, Line: 2, Column: 3, Construct: "string literal"}},
+		{"SELECT \"é\", \"col", QuerySyntaxError{Offset: 13, Line: 1, Column: 13, Construct: "quoted identifier"}},
+		{"SELECT 1 /* a /* b */ @a", QuerySyntaxError{Offset: 9, Line: 1, Column: 10, Construct: "block comment"}},
+		{"DO $x$ BEGIN\nEND $$", QuerySyntaxError{Offset: 3, Line: 1, Column: 4, Construct: "dollar-quoted string"}},
+	}
+	for _, c := range cases {
+		_, _, err := TryRewriteNamedQuery(c.named)
+		var syntaxErr *QuerySyntaxError
+		if assert.ErrorAs(t, err, &syntaxErr, c.named) {
+			assert.Equal(t, c.expected, *syntaxErr, c.named)
+		}
+	}
+
+	_, _, err := MaybeExtractNamedQuery("SELECT 'abc @a", Foo{})
+	assert.EqualError(t, err, "unterminated string literal starting at line 1, column 8 (offset 7)")
+	// the lenient version leaves the error to the server
+	query, args := ExtractNamedQuery("SELECT @b, 'abc @a", Foo{B: "x"})
+	assert.Equal(t, SQL("SELECT $1, 'abc @a"), query)
+	assert.Equal(t, []any{"x"}, args)
+	// line comments may end the query
+	_, _, err = TryRewriteNamedQuery("SELECT @a -- comment")
+	assert.NoError(t, err)
+}
+
+// Reconstructs the source of a parsed query from its parts.
+func (q *parsedQuery) source() string {
+	sb := strings.Builder{}
+	for _, p := range q.parts {
+		if p.field < 0 {
+			sb.WriteString(p.text)
+		} else {
+			sb.WriteString("@" + string(q.fields[p.field]))
+			if p.expand {
+				sb.WriteString("...")
+			}
+		}
+	}
+	return sb.String()
+}
+
+func isASCII(s string) bool {
+	for i := range len(s) {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+func FuzzRewriteNamedQuery(f *testing.F) {
+	f.Add(`SELECT * FROM users WHERE user_id = @user_id AND name = @name`)
+	f.Add(`SELECT @a, '@b', "@c", E'\'@d' -- @e` + "\n" + `/* @f /* @g */ */ @h`)
+	f.Add(`CREATE FUNCTION f() RETURNS int AS $body$ SELECT @x $body$ LANGUAGE sql; SELECT $1, @y`)
+	f.Add(`SELECT * FROM t WHERE id IN (@ids...) AND a = @a`)
+	f.Add(`SELECT @prénom, '` + "\xff" + `', @b`)
+	f.Fuzz(func(t *testing.T, src string) {
+		parsed := parseNamedQuery(SQL(src))
+		// no text is lost or duplicated
+		if parsed.source() != src {
+			t.Fatalf("parts of %q reconstruct to %q", src, parsed.source())
+		}
+		seen := make(map[FieldName]bool)
+		for _, f := range parsed.fields {
+			if f == "" || seen[f] {
+				t.Fatalf("invalid or duplicate field %q in %q", f, src)
+			}
+			seen[f] = true
+		}
+
+		// without the extensions of this lexer (dollar quotes, Unicode names, dotted paths, and expansion), the result matches the original one in pgx
+		if isASCII(src) && !strings.ContainsAny(src, "$.") {
+			query, fields := RewriteNamedQuery(SQL(src))
+			pgxQuery, pgxArgs, err := pgx.NamedArgs{}.RewriteQuery(context.Background(), nil, src, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(query) != pgxQuery || len(fields) != len(pgxArgs) {
+				t.Fatalf("rewriting %q produced %q with %d parameters, pgx produced %q with %d", src, query, len(fields), pgxQuery, len(pgxArgs))
+			}
+		}
+	})
+}
@@ -0,0 +1,54 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// A row from a query whose shape is not known at compile time,
+// keeping the order, names, and types of its columns.
+// Can be used as the result type of Query and ScanRows (as can map[string]any, which loses column order).
+type Record struct {
+	Fields []pgconn.FieldDescription // shared between all records from the same result
+	Values []any                     // decoded using the connection's type map
+}
+
+// Returns the names of the columns of a record in order.
+func (r Record) Names() []string {
+	names := make([]string, len(r.Fields))
+	for i, fd := range r.Fields {
+		names[i] = fd.Name
+	}
+	return names
+}
+
+// Returns the value of the first column with the given name, and whether such a column exists.
+func (r Record) Get(name string) (any, bool) {
+	for i, fd := range r.Fields {
+		if fd.Name == name {
+			return r.Values[i], true
+		}
+	}
+	return nil, false
+}
+
+// Returns the values of a record keyed by column name.
+// If multiple columns have the same name, the last one is used.
+func (r Record) Map() map[string]any {
+	out := make(map[string]any, len(r.Fields))
+	for i, fd := range r.Fields {
+		out[fd.Name] = r.Values[i]
+	}
+	return out
+}
+
+func scanRecord(rows pgx.Rows) (Record, error) {
+	values, err := rows.Values()
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{Fields: rows.FieldDescriptions(), Values: values}, nil
+}
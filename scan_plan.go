@@ -0,0 +1,390 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Precomputed mapping from the columns of a result set to the fields of a struct,
+// so that scanning a row does not require any name lookups.
+type scanPlan struct {
+	mapping   structMapping
+	columns   []FieldName
+	paths     [][]int // field index path of each column, nil for unmapped columns
+	owners    []int   // innermost optional struct containing each column, -1 if none
+	json      []bool  // columns tagged with the json option
+	optionals []optionalStruct
+	err       error // deferred until the first row is scanned
+	mismatch  *ScanMismatchError
+	// whether pointers to structs in the top-level fields are optional without a db_prefix tag, as for tuple members
+	optionalMembers bool
+}
+
+// A pointer to a struct nested within the scanned type (such as a db_prefix field of a LEFT JOIN),
+// which is left nil if all of its columns are NULL.
+// Whether it is NULL is decided from the raw values of each row before scanning,
+// so that its fields can be scanned directly into a freshly allocated struct.
+// An optional struct with an empty path refers to the scanned struct itself.
+type optionalStruct struct {
+	path   []int // index path to the pointer field
+	parent int   // enclosing optional struct, -1 if none
+	cols   []int // all columns contained within, including those of nested optional structs
+}
+
+// Kinds of plans held in the scan plan cache.
+type planKind uint8
+
+const (
+	structPlan planKind = iota
+	tuplePlan
+	groupPlanKind
+)
+
+type scanPlanKey struct {
+	kind    planKind
+	t       reflect.Type
+	columns string // column names separated by NUL
+	split   string // division of the columns between the members of a tuple
+}
+
+// Result of making a plan, which is cached even if it failed.
+type cachedPlan struct {
+	plan any
+	err  error
+}
+
+// LRU cache of plans for scanning structs, tuples, and grouped rows.
+var scanPlans = newLRUCache[scanPlanKey, cachedPlan](1024)
+
+// Sets the maximum number of scan plans (for a struct type and list of columns) to cache (1024 by default),
+// evicting the least recently used. Set to 0 to disable caching. Safe to call at any time.
+func SetScanPlanCacheSize(size int) {
+	scanPlans.setSize(size)
+}
+
+// Gets a plan from the cache, making it if it is missing.
+// Plans are never modified once made, so can be shared between goroutines.
+func cachedPlanOf[P any](key scanPlanKey, makePlan func() (P, error)) (P, error) {
+	if c, ok := scanPlans.get(key); ok {
+		return c.plan.(P), c.err
+	}
+	p, err := makePlan()
+	scanPlans.put(key, cachedPlan{plan: p, err: err})
+	return p, err
+}
+
+// Joins the names of the columns of a result set into a cache key.
+func columnsKey(fields []pgconn.FieldDescription) string {
+	var key strings.Builder
+	for i, fd := range fields {
+		if i > 0 {
+			key.WriteByte(0)
+		}
+		key.WriteString(fd.Name)
+	}
+	return key.String()
+}
+
+func makeScanPlan(t reflect.Type, columns []FieldName) *scanPlan {
+	p := &scanPlan{
+		mapping: structMappingOf(t),
+		columns: columns,
+		paths:   make([][]int, len(columns)),
+		owners:  make([]int, len(columns)),
+		json:    make([]bool, len(columns)),
+	}
+	var unmapped, unfilled []FieldName
+	filled := make(map[FieldName]bool, len(columns))
+	for i, c := range columns {
+		idx, found := p.mapping.FieldMappings[c]
+		if !found {
+			if p.err == nil {
+				p.err = fmt.Errorf("missing database field %s in struct %s", c, p.mapping.StructType.Name())
+			}
+			unmapped = append(unmapped, c)
+			p.owners[i] = -1
+			continue
+		}
+		p.paths[i] = idx
+		p.owners[i] = p.addOptionals(i, idx, -1)
+		p.json[i] = p.mapping.FieldOptions[c].has("json")
+		filled[c] = true
+	}
+	for _, f := range p.mapping.FieldList {
+		if !filled[f] {
+			unfilled = append(unfilled, f)
+		}
+	}
+	if unmapped != nil || unfilled != nil {
+		p.mismatch = &ScanMismatchError{
+			StructType:      p.mapping.StructType,
+			UnmappedColumns: unmapped,
+			UnfilledFields:  unfilled,
+		}
+	}
+	return p
+}
+
+// Makes a plan scanning only some of the columns of a result set (those with a non-empty local name),
+// for which other columns must be given scan targets separately.
+// If optional is set, the struct itself is considered to be NULL if all of its columns are.
+func makePartialScanPlan(t reflect.Type, localNames []FieldName, optional bool) *scanPlan {
+	p := &scanPlan{
+		mapping: structMappingOf(t),
+		columns: localNames,
+		paths:   make([][]int, len(localNames)),
+		owners:  make([]int, len(localNames)),
+		json:    make([]bool, len(localNames)),
+	}
+	root := -1
+	if optional {
+		p.optionals = []optionalStruct{{path: nil, parent: -1}}
+		root = 0
+	}
+	for i, c := range localNames {
+		p.owners[i] = -1
+		idx, found := p.mapping.FieldMappings[c]
+		if !found {
+			continue
+		}
+		p.paths[i] = idx
+		if optional {
+			p.optionals[0].cols = append(p.optionals[0].cols, i)
+		}
+		p.owners[i] = p.addOptionals(i, idx, root)
+		p.json[i] = p.mapping.FieldOptions[c].has("json")
+	}
+	return p
+}
+
+// Registers column i in all of the optional structs along its path (creating them as needed),
+// returning the innermost one, or owner if there are none.
+// Only pointers to db_prefix structs are optional, other embedded pointers are always allocated.
+func (p *scanPlan) addOptionals(i int, path []int, owner int) int {
+	t := derefType(p.mapping.StructType)
+	for k, j := range path[:len(path)-1] {
+		ft := t.Field(j).Type
+		_, isPrefix := t.Field(j).Tag.Lookup("db_prefix")
+		if ft.Kind() == reflect.Pointer && (isPrefix || (k == 0 && p.optionalMembers)) {
+			prefix := path[:k+1]
+			n := slices.IndexFunc(p.optionals, func(o optionalStruct) bool { return slices.Equal(o.path, prefix) })
+			if n < 0 {
+				n = len(p.optionals)
+				p.optionals = append(p.optionals, optionalStruct{path: prefix, parent: owner})
+			}
+			p.optionals[n].cols = append(p.optionals[n].cols, i)
+			owner = n
+		}
+		t = derefType(ft)
+	}
+	return owner
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// Checks the plan against the options of a scan, returning any error to be reported before the first row.
+func (p *scanPlan) check(opts ScanOptions) error {
+	return checkMismatch(p.mismatch, opts)
+}
+
+func checkMismatch(m *ScanMismatchError, opts ScanOptions) error {
+	if !opts.Strict || m == nil {
+		return nil
+	}
+	unmapped := m.UnmappedColumns
+	if opts.IgnoreExtraColumns {
+		unmapped = nil
+	}
+	if unmapped == nil && m.UnfilledFields == nil {
+		return nil
+	}
+	mismatch := &ScanMismatchError{
+		StructType:      m.StructType,
+		UnmappedColumns: unmapped,
+		UnfilledFields:  m.UnfilledFields,
+	}
+	if unmapped == nil && opts.WarnUnfilled != nil {
+		opts.WarnUnfilled(mismatch)
+		return nil
+	}
+	return mismatch
+}
+
+// Error returned by strict scans listing all mismatches between the columns of a result set and the fields of a struct.
+type ScanMismatchError struct {
+	StructType      reflect.Type
+	UnmappedColumns []FieldName // result columns without a matching struct field
+	UnfilledFields  []FieldName // db-tagged struct fields not set by any column
+}
+
+func (e *ScanMismatchError) Error() string {
+	var problems []string
+	if len(e.UnmappedColumns) > 0 {
+		problems = append(problems, fmt.Sprintf("unmapped columns %v", e.UnmappedColumns))
+	}
+	if len(e.UnfilledFields) > 0 {
+		problems = append(problems, fmt.Sprintf("unfilled fields %v", e.UnfilledFields))
+	}
+	return fmt.Sprintf("result does not match struct %s: %s", e.StructType.Name(), strings.Join(problems, ", "))
+}
+
+func columnNames(fields []pgconn.FieldDescription) []FieldName {
+	columns := make([]FieldName, len(fields))
+	for i, fd := range fields {
+		columns[i] = FieldName(fd.Name)
+	}
+	return columns
+}
+
+// Gets the cached scan plan for a struct type and the columns of a result set.
+func scanPlanOf(t reflect.Type, fields []pgconn.FieldDescription) *scanPlan {
+	key := scanPlanKey{kind: structPlan, t: t, columns: columnsKey(fields)}
+	p, _ := cachedPlanOf(key, func() (*scanPlan, error) {
+		return makeScanPlan(t, columnNames(fields)), nil
+	})
+	return p
+}
+
+// Per-query state for scanning rows using a plan, reused between rows.
+type scanState struct {
+	plan    *scanPlan
+	jsons   []jsonScanTarget // raw values of columns tagged with the json option
+	present []bool           // which optional structs are non-null in the current row
+}
+
+// Creates the state for scanning with a plan, setting the scan targets in ptrs which do not change between rows.
+func (p *scanPlan) newScanState(ptrs []any) *scanState {
+	s := &scanState{
+		plan:    p,
+		jsons:   make([]jsonScanTarget, len(p.columns)),
+		present: make([]bool, len(p.optionals)),
+	}
+	for i, path := range p.paths {
+		if path != nil && p.json[i] {
+			ptrs[i] = &s.jsons[i]
+		}
+	}
+	return s
+}
+
+func fieldTypeByPath(t reflect.Type, path []int) reflect.Type {
+	for _, j := range path {
+		t = derefType(t).Field(j).Type
+	}
+	return t
+}
+
+// Walks an index path through a struct, allocating any nil embedded pointers along the way.
+func fieldByPath(v reflect.Value, path []int) reflect.Value {
+	for _, j := range path {
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(j)
+	}
+	return v
+}
+
+// Dereferences a pointer to the destination of a scan, allocating any nil pointers.
+func scanDestination(ptr reflect.Value) reflect.Value {
+	root := ptr.Elem()
+	for root.Kind() == reflect.Pointer {
+		if root.IsNil() {
+			root.Set(reflect.New(root.Type().Elem()))
+		}
+		root = root.Elem()
+	}
+	return root
+}
+
+// Sets the scan targets in ptrs for the fields of root (an addressable struct) before scanning a row.
+// Optional structs are found to be NULL from the raw values of the row, and are set to nil if so,
+// or to a freshly allocated struct (never reusing one already in root) to be scanned into directly.
+// Returns false if the entire struct is optional and all of its columns are NULL.
+func (s *scanState) prepare(ptrs []any, root reflect.Value, raw [][]byte) bool {
+	p := s.plan
+
+	// optional structs are ordered parents first, so each only needs to be checked if its parent is present
+	for n, o := range p.optionals {
+		s.present[n] = (o.parent < 0 || s.present[o.parent]) && anyNonNull(o.cols, raw)
+		if len(o.path) == 0 || (o.parent >= 0 && !s.present[o.parent]) {
+			continue
+		}
+		fval := fieldByPath(root, o.path)
+		if s.present[n] {
+			fval.Set(reflect.New(fval.Type().Elem()))
+		} else {
+			fval.SetZero()
+		}
+	}
+	for i, path := range p.paths {
+		if path == nil || p.json[i] {
+			continue
+		} else if owner := p.owners[i]; owner >= 0 && !s.present[owner] {
+			ptrs[i] = nil
+		} else {
+			ptrs[i] = fieldByPath(root, path).Addr().Interface()
+		}
+	}
+	return len(p.optionals) == 0 || len(p.optionals[0].path) > 0 || s.present[0]
+}
+
+// Returns whether any of the given columns are non-null in the raw values of a row.
+func anyNonNull(cols []int, raw [][]byte) bool {
+	for _, i := range cols {
+		if raw[i] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Decodes JSON columns into root after a row has been scanned.
+func (s *scanState) finish(root reflect.Value) error {
+	p := s.plan
+	for i, owner := range p.owners {
+		if !p.json[i] || (owner >= 0 && !s.present[owner]) {
+			continue
+		}
+		err := s.jsons[i].decodeInto(fieldByPath(root, p.paths[i]))
+		if err != nil {
+			return fmt.Errorf("error decoding database field %s in struct %s: %w", p.columns[i], p.mapping.StructType.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Prepares a function scanning the current row of a cursor into the struct pointed to by its argument.
+// If ignoreExtra is set, unmapped columns are skipped (given nil scan targets) instead of causing an error.
+func (p *scanPlan) rowScanner(rows pgx.Rows, ignoreExtra bool) func(ptr reflect.Value) error {
+	ptrs := make([]any, len(p.columns))
+	state := p.newScanState(ptrs)
+	return func(ptr reflect.Value) error {
+		if p.err != nil && !ignoreExtra {
+			return p.err
+		}
+		root := scanDestination(ptr)
+		state.prepare(ptrs, root, rows.RawValues())
+		err := rows.Scan(ptrs...)
+		if err != nil {
+			return err
+		}
+		return state.finish(root)
+	}
+}
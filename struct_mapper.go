@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"reflect"
 	"slices"
+	"strings"
 	"sync"
 )
 
@@ -26,30 +27,69 @@ type structMapping struct {
 	StructType    reflect.Type
 	FieldList     []FieldName
 	FieldMappings map[FieldName][]int
+	FieldOptions  map[FieldName]tagOptions
+	// dotted paths to fields inside db_prefix structs (such as u.name for u_name), used as named parameters
+	DottedNames map[FieldName]FieldName
 }
 
+// Options following the column name in a db tag, such as `db:"user_id,pk,generated"`.
+type tagOptions []string
+
+// Options recognized in db tags:
+//   - pk: the field is part of the primary key (returned by KeyFields, excluded from UpdatableFields,
+//     and identifying records in grouped scans)
+//   - generated, readonly: the field is set by the database and is never inserted or updated
+//   - default, omitinsert: the field is left to its default value when inserting
+//   - insertonly: the field is set when inserting but never updated
+//   - json: the field is encoded and decoded as JSON
+var knownTagOptions = []string{"pk", "generated", "readonly", "default", "omitinsert", "insertonly", "json"}
+
+func (o tagOptions) has(opt string) bool {
+	return slices.Contains(o, opt)
+}
+
+// Splits a db tag into a column name and options.
+func parseDBTag(tag string) (string, tagOptions) {
+	name, opts, hasOpts := strings.Cut(tag, ",")
+	if !hasOpts {
+		return name, nil
+	}
+	return name, strings.Split(opts, ",")
+}
+
+// Must be called with structMappingsLock held.
 func makeStructMapping(t reflect.Type) (structMapping, error) {
 	m := structMapping{
 		StructType:    t,
 		FieldList:     nil,
 		FieldMappings: make(map[FieldName][]int),
+		FieldOptions:  make(map[FieldName]tagOptions),
+		DottedNames:   make(map[FieldName]FieldName),
 	}
-	err := extendStructMapping(&m, t, "", nil)
+	err := extendStructMapping(&m, t, "", "", nil, defaultNamingPolicy)
 	return m, err
 }
 
-func extendStructMapping(m *structMapping, t reflect.Type, field_prefix string, path_prefix []int) error {
+func extendStructMapping(m *structMapping, t reflect.Type, field_prefix string, dotted_prefix string, path_prefix []int, policy NamingPolicy) error {
 	switch t.Kind() {
 	case reflect.Pointer:
-		return extendStructMapping(m, t.Elem(), field_prefix, path_prefix)
+		return extendStructMapping(m, t.Elem(), field_prefix, dotted_prefix, path_prefix, policy)
 	case reflect.Struct:
+		if p := namingPolicyOf(t); p != nil {
+			policy = p
+		}
 		for i := range t.NumField() {
 			f := t.Field(i)
-			dbtag := f.Tag.Get("db")
+			rawtag := f.Tag.Get("db")
+			dbtag, opts := parseDBTag(rawtag)
 			prefixtag, isprefix := f.Tag.Lookup("db_prefix")
-			if !f.IsExported() {
+			_, ischildren := f.Tag.Lookup("db_children")
+			if !f.IsExported() || rawtag == "-" {
 				continue
 			}
+			if dbtag == "" && policy != nil && !f.Anonymous && !isprefix && !ischildren {
+				dbtag = policy(f.Name)
+			}
 			if dbtag != "" {
 				name := FieldName(field_prefix + dbtag)
 				m.FieldList = append(m.FieldList, name)
@@ -61,8 +101,29 @@ func extendStructMapping(m *structMapping, t reflect.Type, field_prefix string,
 				} else {
 					m.FieldMappings[name] = slices.Concat(path_prefix, []int{i})
 				}
+				for _, opt := range opts {
+					if !slices.Contains(knownTagOptions, opt) {
+						return fmt.Errorf("unknown option %s in db tag of field %s", opt, name)
+					}
+				}
+				if opts != nil {
+					m.FieldOptions[name] = opts
+				}
+				if dotted_prefix != "" {
+					dotted := FieldName(dotted_prefix + dbtag)
+					if _, ambiguous := m.DottedNames[dotted]; ambiguous {
+						// prefixes differing only by a trailing underscore, leave it unresolvable
+						m.DottedNames[dotted] = ""
+					} else {
+						m.DottedNames[dotted] = name
+					}
+				}
 			} else if f.Anonymous || isprefix {
-				err := extendStructMapping(m, f.Type, field_prefix+prefixtag, slices.Concat(path_prefix, []int{i}))
+				segment := strings.TrimSuffix(prefixtag, "_")
+				if segment != "" {
+					segment += "."
+				}
+				err := extendStructMapping(m, f.Type, field_prefix+prefixtag, dotted_prefix+segment, slices.Concat(path_prefix, []int{i}), policy)
 				if err != nil {
 					return err
 				}
@@ -109,6 +170,45 @@ func DBFields[T any]() []FieldName {
 	return structMappingFor[T]().FieldList
 }
 
+// Returns the fields of a mapping having any of the given tag options (or lacking all of them if exclude is set).
+func (m structMapping) filterFields(opts []string, exclude bool) []FieldName {
+	var out []FieldName
+	for _, f := range m.FieldList {
+		found := slices.ContainsFunc(opts, m.FieldOptions[f].has)
+		if found != exclude {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Returns the db fields of a struct tagged with any of the given options (such as `db:"user_id,pk"`).
+func FieldsWithOption[T any](opts ...string) []FieldName {
+	return structMappingFor[T]().filterFields(opts, false)
+}
+
+// Returns the db fields of a struct tagged with none of the given options.
+func FieldsWithoutOption[T any](opts ...string) []FieldName {
+	return structMappingFor[T]().filterFields(opts, true)
+}
+
+// Returns the db fields of a struct making up its primary key (tagged with the pk option).
+func KeyFields[T any]() []FieldName {
+	return FieldsWithOption[T]("pk")
+}
+
+// Returns the db fields of a struct which should be set when inserting (such as with [NamedInsertQuery]),
+// skipping those tagged with the generated, readonly, default, or omitinsert options.
+func InsertableFields[T any]() []FieldName {
+	return FieldsWithoutOption[T]("generated", "readonly", "default", "omitinsert")
+}
+
+// Returns the db fields of a struct which should be set when updating,
+// skipping those tagged with the pk, generated, readonly, or insertonly options.
+func UpdatableFields[T any]() []FieldName {
+	return FieldsWithoutOption[T]("pk", "generated", "readonly", "insertonly")
+}
+
 func (m structMapping) extractNamedArgs(fields []FieldName, val reflect.Value) ([]any, error) {
 	args := make([]any, len(fields))
 
@@ -117,38 +217,52 @@ func (m structMapping) extractNamedArgs(fields []FieldName, val reflect.Value) (
 	}
 
 	for i, f := range fields {
-		idx, found := m.FieldMappings[f]
-		if !found {
+		arg, found, err := m.namedArg(f, val)
+		if err != nil {
+			return nil, err
+		} else if !found {
 			return nil, fmt.Errorf("missing database field %s in struct %s", f, m.StructType.Name())
 		}
-		fval := val.FieldByIndex(idx)
-		args[i] = fval.Interface()
+		args[i] = arg
 	}
 	return args, nil
 }
 
+// Extracts the value of a single named parameter from a struct, reporting whether the struct has the field.
+func (m structMapping) namedArg(f FieldName, val reflect.Value) (any, bool, error) {
+	if name, dotted := m.DottedNames[f]; dotted {
+		f = name
+	}
+	idx, found := m.FieldMappings[f]
+	if !found {
+		return nil, false, nil
+	}
+	fval, err := val.FieldByIndexErr(idx)
+	if err != nil {
+		// fields inside a nil embedded pointer are NULL
+		return nil, true, nil
+	}
+	if m.FieldOptions[f].has("json") {
+		arg, err := jsonArg(fval)
+		if err != nil {
+			return nil, true, fmt.Errorf("error encoding database field %s in struct %s: %w", f, m.StructType.Name(), err)
+		}
+		return arg, true, nil
+	}
+	return fval.Interface(), true, nil
+}
+
+// Returns pointers to the fields of the struct pointed to by ptr for each of the given fields,
+// allocating any nil embedded pointers along the way.
 func (m structMapping) extractScanPointers(fields []FieldName, ptr reflect.Value) ([]any, error) {
 	args := make([]any, len(fields))
-
 	val := ptr.Elem()
-
 	for i, f := range fields {
 		idx, found := m.FieldMappings[f]
 		if !found {
 			return nil, fmt.Errorf("missing database field %s in struct %s", f, m.StructType.Name())
 		}
-		fval := val
-		for _, j := range idx {
-			// allocate if an embedded pointer is nil
-			for fval.Kind() == reflect.Pointer {
-				if fval.IsNil() {
-					fval.Set(reflect.New(fval.Type().Elem()))
-				}
-				fval = fval.Elem()
-			}
-			fval = fval.Field(j)
-		}
-		args[i] = fval.Addr().Interface()
+		args[i] = fieldByPath(val, idx).Addr().Interface()
 	}
 	return args, nil
 }
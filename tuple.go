@@ -0,0 +1,263 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Two records read from the same row of a result, such as both sides of a join.
+type Tuple2[A, B any] struct {
+	A A
+	B B
+}
+
+// Three records read from the same row of a result.
+type Tuple3[A, B, C any] struct {
+	A A
+	B B
+	C C
+}
+
+// Splits a slice of pairs into parallel slices.
+func Unzip2[A, B any](tuples []Tuple2[A, B]) ([]A, []B) {
+	as := make([]A, len(tuples))
+	bs := make([]B, len(tuples))
+	for i, t := range tuples {
+		as[i] = t.A
+		bs[i] = t.B
+	}
+	return as, bs
+}
+
+// Splits a slice of triples into parallel slices.
+func Unzip3[A, B, C any](tuples []Tuple3[A, B, C]) ([]A, []B, []C) {
+	as := make([]A, len(tuples))
+	bs := make([]B, len(tuples))
+	cs := make([]C, len(tuples))
+	for i, t := range tuples {
+		as[i] = t.A
+		bs[i] = t.B
+		cs[i] = t.C
+	}
+	return as, bs, cs
+}
+
+// Describes how the columns of a result are divided between the members of a tuple.
+type TupleSplit struct {
+	prefixes []string
+	starts   []int
+}
+
+// Divides columns by prefix, with each member of a tuple taking the columns starting with its prefix
+// (which is removed before mapping them to fields). If prefixes overlap, the longest match is used.
+// Use with [ListFieldsWithPrefix] in the same way as the db_prefix tag.
+func SplitByPrefix(prefixes ...string) TupleSplit {
+	return TupleSplit{prefixes: prefixes}
+}
+
+// Divides columns by position, with the first member of a tuple taking the leading columns
+// and each subsequent member starting at the given column index.
+// Useful for joins such as `SELECT a.*, b.* ...` where column names collide.
+func SplitAt(starts ...int) TupleSplit {
+	return TupleSplit{starts: starts}
+}
+
+// Makes a plan scanning columns into the members of a tuple (any struct with one field per member).
+// Members may be structs (mapped by db tags), pointers to structs (left nil if all of their columns are NULL),
+// or single values (taking a single column named by its prefix, or a single position).
+func makeTuplePlan(t reflect.Type, split TupleSplit, columns []FieldName) (*scanPlan, error) {
+	nmembers := t.NumField()
+	if split.prefixes != nil && len(split.prefixes) != nmembers {
+		return nil, fmt.Errorf("expected %d prefixes to split %s, got %d", nmembers, t.Name(), len(split.prefixes))
+	}
+	if split.prefixes == nil && len(split.starts) != nmembers-1 {
+		return nil, fmt.Errorf("expected %d split positions for %s, got %d", nmembers-1, t.Name(), len(split.starts))
+	}
+	if !slices.IsSorted(split.starts) {
+		return nil, fmt.Errorf("split positions %v are not in order", split.starts)
+	}
+
+	p := &scanPlan{
+		mapping:         structMapping{StructType: t},
+		columns:         columns,
+		paths:           make([][]int, len(columns)),
+		owners:          make([]int, len(columns)),
+		json:            make([]bool, len(columns)),
+		optionalMembers: true,
+	}
+	// path within a member of a local column name
+	lookup := func(k int, local FieldName) ([]int, bool) {
+		mt := t.Field(k).Type
+		if !isMappable(mt) {
+			return []int{k}, local == ""
+		}
+		idx, found := structMappingOf(mt).FieldMappings[local]
+		if !found {
+			return nil, false
+		}
+		return slices.Concat([]int{k}, idx), true
+	}
+
+	var unmapped []FieldName
+	filled := make([]map[FieldName]bool, nmembers)
+	for k := range filled {
+		filled[k] = make(map[FieldName]bool)
+	}
+	for i, c := range columns {
+		p.owners[i] = -1
+		member, local := -1, FieldName("")
+		if split.prefixes != nil {
+			for k, prefix := range split.prefixes {
+				rest, hasPrefix := strings.CutPrefix(string(c), prefix)
+				if !hasPrefix || (member >= 0 && len(prefix) <= len(split.prefixes[member])) {
+					continue
+				}
+				if _, found := lookup(k, FieldName(rest)); found {
+					member, local = k, FieldName(rest)
+				}
+			}
+		} else {
+			k, _ := slices.BinarySearch(split.starts, i+1)
+			if isMappable(t.Field(k).Type) {
+				local = c
+			}
+			if _, found := lookup(k, local); found && !filled[k][local] {
+				member = k
+			}
+		}
+		if member < 0 {
+			if p.err == nil {
+				p.err = fmt.Errorf("missing database field %s in struct %s", c, t.Name())
+			}
+			unmapped = append(unmapped, c)
+			continue
+		}
+		p.paths[i], _ = lookup(member, local)
+		p.owners[i] = p.addOptionals(i, p.paths[i], -1)
+		if mt := t.Field(member).Type; isMappable(mt) {
+			p.json[i] = structMappingOf(mt).FieldOptions[local].has("json")
+		}
+		filled[member][local] = true
+	}
+
+	var unfilled []FieldName
+	for k := range nmembers {
+		prefix := FieldName("")
+		if split.prefixes != nil {
+			prefix = FieldName(split.prefixes[k])
+		}
+		mt := t.Field(k).Type
+		if !isMappable(mt) {
+			if !filled[k][""] {
+				unfilled = append(unfilled, prefix)
+			}
+			continue
+		}
+		for _, f := range structMappingOf(mt).FieldList {
+			if !filled[k][f] {
+				unfilled = append(unfilled, prefix+f)
+			}
+		}
+	}
+	if unmapped != nil || unfilled != nil {
+		p.mismatch = &ScanMismatchError{
+			StructType:      t,
+			UnmappedColumns: unmapped,
+			UnfilledFields:  unfilled,
+		}
+	}
+	return p, nil
+}
+
+// Gets the cached plan for a tuple type, split, and the columns of a result set.
+func tuplePlanOf(t reflect.Type, split TupleSplit, fields []pgconn.FieldDescription) (*scanPlan, error) {
+	key := scanPlanKey{kind: tuplePlan, t: t, columns: columnsKey(fields), split: fmt.Sprintf("%q %v", split.prefixes, split.starts)}
+	return cachedPlanOf(key, func() (*scanPlan, error) {
+		return makeTuplePlan(t, split, columnNames(fields))
+	})
+}
+
+// Scan rows to a slice of tuples (such as [Tuple2]), dividing the columns of each row between the members.
+// Each member is either a struct (using the mapping defined by db and db_prefix tags),
+// a pointer to a struct (left nil if all of its columns are NULL), or a single value.
+func ScanTuples[T any](rows pgx.Rows, split TupleSplit, dst *[]T) error {
+	return ScanTuplesWithOptions(rows, split, dst, DefaultScanOptions)
+}
+
+// Version of ScanTuples with explicit options for matching columns to struct fields.
+func ScanTuplesWithOptions[T any](rows pgx.Rows, split TupleSplit, dst *[]T, opts ScanOptions) error {
+	defer rows.Close()
+	plan, err := tuplePlanOf(reflect.TypeFor[T](), split, rows.FieldDescriptions())
+	if err != nil {
+		return err
+	}
+	err = plan.check(opts)
+	if err != nil {
+		return err
+	}
+	scan := plan.rowScanner(rows, opts.IgnoreExtraColumns)
+
+	*dst = (*dst)[:0]
+	for rows.Next() {
+		var record T
+		err = scan(reflect.ValueOf(&record))
+		if err != nil {
+			return err
+		}
+		*dst = append(*dst, record)
+	}
+	return rows.Err()
+}
+
+// Run a query with positional parameters and read out each row as a pair of records,
+// dividing the columns between them as described by split.
+func Query2[A, B any](ctx context.Context, conn PoolOrTx, split TupleSplit, query SQL, args ...any) ([]Tuple2[A, B], error) {
+	cursor, err := conn.Query(ctx, string(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	var out []Tuple2[A, B]
+	err = ScanTuples(cursor, split, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Run a query with positional parameters and read out each row as a triple of records,
+// dividing the columns between them as described by split.
+func Query3[A, B, C any](ctx context.Context, conn PoolOrTx, split TupleSplit, query SQL, args ...any) ([]Tuple3[A, B, C], error) {
+	cursor, err := conn.Query(ctx, string(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	var out []Tuple3[A, B, C]
+	err = ScanTuples(cursor, split, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Run a query with named parameters (pulling them out of a struct) and read out each row as a pair of records,
+// dividing the columns between them as described by split.
+func NamedQuery2[A, B any](ctx context.Context, conn PoolOrTx, split TupleSplit, namedQuery SQL, argsStruct any) ([]Tuple2[A, B], error) {
+	query, args := ExtractNamedQuery(namedQuery, argsStruct)
+	return Query2[A, B](ctx, conn, split, query, args...)
+}
+
+// Run a query with named parameters (pulling them out of a struct) and read out each row as a triple of records,
+// dividing the columns between them as described by split.
+func NamedQuery3[A, B, C any](ctx context.Context, conn PoolOrTx, split TupleSplit, namedQuery SQL, argsStruct any) ([]Tuple3[A, B, C], error) {
+	query, args := ExtractNamedQuery(namedQuery, argsStruct)
+	return Query3[A, B, C](ctx, conn, split, query, args...)
+}
@@ -0,0 +1,49 @@
+// Copyright 2024-2025 George Steel
+// SPDX-License-Identifier: MIT
+
+package pgxx
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanTuplesByPrefix(t *testing.T) {
+	cols := []fakeCol{{"f_a", pgtype.Int8OID}, {"f_b", pgtype.TextOID}, {"f_c", pgtype.Float8OID}, {"bar_c", pgtype.Float8OID}, {"n", pgtype.Int4OID}}
+	rows := newFakeRows(cols, textRow("1", "a", "1.5", "2.5", "3"), []*string{textRow("2")[0], textRow("b")[0], textRow("0")[0], nil, nil})
+	var out []Tuple3[Foo, *Bar, *int]
+	err := ScanTuples(rows, SplitByPrefix("f_", "bar_", "n"), &out)
+	assert.NoError(t, err)
+	three := 3
+	assert.Equal(t, []Tuple3[Foo, *Bar, *int]{
+		{A: Foo{A: 1, B: "a", Bar: Bar{C: 1.5}}, B: &Bar{C: 2.5}, C: &three},
+		{A: Foo{A: 2, B: "b"}},
+	}, out)
+
+	foos, bars, _ := Unzip3(out)
+	assert.Len(t, foos, 2)
+	assert.Len(t, bars, 2)
+}
+
+func TestScanTuplesByPosition(t *testing.T) {
+	// colliding column names are resolved by position
+	cols := []fakeCol{{"a", pgtype.Int8OID}, {"b", pgtype.TextOID}, {"c", pgtype.Float8OID}, {"c", pgtype.Float8OID}}
+	var out []Tuple2[Foo, Bar]
+	err := ScanTuples(newFakeRows(cols, textRow("1", "a", "1.5", "2.5")), SplitAt(3), &out)
+	assert.NoError(t, err)
+	assert.Equal(t, []Tuple2[Foo, Bar]{{A: Foo{A: 1, B: "a", Bar: Bar{C: 1.5}}, B: Bar{C: 2.5}}}, out)
+
+	err = ScanTuples(newFakeRows(cols, textRow("1", "a", "1.5", "2.5")), SplitAt(2), &out)
+	assert.EqualError(t, err, "missing database field c in struct Tuple2[github.com/george-steel/pgxx.Foo,github.com/george-steel/pgxx.Bar]")
+}
+
+func TestScanTuplesWithOptions(t *testing.T) {
+	cols := []fakeCol{{"a", pgtype.Int8OID}, {"b", pgtype.TextOID}, {"c", pgtype.Float8OID}, {"c", pgtype.Float8OID}}
+	var out []Tuple2[Foo, Bar]
+	// the second c column is extra once both are given to Bar
+	err := ScanTuplesWithOptions(newFakeRows(cols, textRow("1", "a", "1.5", "2.5")), SplitAt(2), &out, ScanOptions{IgnoreExtraColumns: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []Tuple2[Foo, Bar]{{A: Foo{A: 1, B: "a"}, B: Bar{C: 1.5}}}, out)
+}